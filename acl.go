@@ -0,0 +1,194 @@
+package periodic
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Lupino/go-periodic/protocol"
+	"github.com/Lupino/periodic/driver"
+	"io/ioutil"
+	"log"
+	"path"
+	"sync"
+)
+
+// aclRule defined what an identity is allowed to do
+type aclRule struct {
+	Roles    []string `json:"roles"`    // client, worker or admin, admin is allowed everything
+	Commands []string `json:"commands"` // The client commands, empty to allow all
+	Funcs    []string `json:"funcs"`    // The func patterns to submit or CANDO, empty to allow all
+}
+
+// acl defined the access control list loaded from a json file of identity to
+// rule, the rule of `*` is used for the identities not listed.
+type acl struct {
+	file   string
+	rules  map[string]aclRule
+	denied map[string]int
+	locker *sync.Mutex
+}
+
+var errPermissionDenied = errors.New("Permission denied.")
+
+// commandNames the names of the client commands used in acl file
+var commandNames = map[protocol.Command]string{
+	protocol.SUBMITJOB: "SUBMITJOB",
+	protocol.STATUS:    "STATUS",
+	protocol.PING:      "PING",
+	protocol.DROPFUNC:  "DROPFUNC",
+	protocol.REMOVEJOB: "REMOVEJOB",
+	CONFIGSET:          "CONFIGSET",
+	CONFIGGET:          "CONFIGGET",
+	LISTJOB:            "LISTJOB",
+	REDRIVEJOB:         "REDRIVEJOB",
+	PURGEJOB:           "PURGEJOB",
+	GETRESULT:          "GETRESULT",
+	SUBMITWAIT:         "SUBMITWAIT",
+	SUBSCRIBE:          "SUBSCRIBE",
+	CANCELJOB:          "CANCELJOB",
+	LISTWORKER:         "LISTWORKER",
+	DRAINWORKER:        "DRAINWORKER",
+	PAUSEFUNC:          "PAUSEFUNC",
+	RESUMEFUNC:         "RESUMEFUNC",
+}
+
+// adminCommands the commands change the schedule of others, only allowed to
+// the admin role.
+var adminCommands = map[string]bool{
+	"DROPFUNC":    true,
+	"PURGEJOB":    true,
+	"CONFIGSET":   true,
+	"PAUSEFUNC":   true,
+	"RESUMEFUNC":  true,
+	"DRAINWORKER": true,
+	"DENIALS":     true,
+}
+
+func loadACL(file string) (map[string]aclRule, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var rules map[string]aclRule
+	if err = json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("%s: %s", file, err)
+	}
+	for identity, rule := range rules {
+		for _, pattern := range rule.Funcs {
+			if _, err = path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("%s: %s: invalid func pattern %q", file, identity, pattern)
+			}
+		}
+	}
+	return rules, nil
+}
+
+// SetACLFile authorize the identities with the acl file
+func (sched *Sched) SetACLFile(file string) error {
+	rules, err := loadACL(file)
+	if err != nil {
+		return err
+	}
+	sched.acl = &acl{
+		file:   file,
+		rules:  rules,
+		denied: make(map[string]int),
+		locker: new(sync.Mutex),
+	}
+	return nil
+}
+
+// ReloadACL load the acl file again, the old rules are kept on error.
+func (sched *Sched) ReloadACL() error {
+	if sched.acl == nil {
+		return nil
+	}
+	rules, err := loadACL(sched.acl.file)
+	if err != nil {
+		return err
+	}
+	defer sched.acl.locker.Unlock()
+	sched.acl.locker.Lock()
+	sched.acl.rules = rules
+	return nil
+}
+
+// Denials returns the denied requests of each identity
+func (sched *Sched) Denials() map[string]int {
+	var denials = make(map[string]int)
+	if sched.acl == nil {
+		return denials
+	}
+	defer sched.acl.locker.Unlock()
+	sched.acl.locker.Lock()
+	for identity, count := range sched.acl.denied {
+		denials[identity] = count
+	}
+	return denials
+}
+
+func contains(items []string, item string) bool {
+	for _, i := range items {
+		if i == item {
+			return true
+		}
+	}
+	return false
+}
+
+// authorize check the identity is allowed the command on func with role, the
+// command is empty to check the role only and Func is empty for the commands
+// not on a func. The admin commands require the admin role.
+func (sched *Sched) authorize(identity, role, command, Func string) error {
+	if sched.acl == nil {
+		return nil
+	}
+	defer sched.acl.locker.Unlock()
+	sched.acl.locker.Lock()
+	rule, ok := sched.acl.rules[identity]
+	if !ok {
+		rule, ok = sched.acl.rules["*"]
+	}
+	if ok && contains(rule.Roles, "admin") {
+		return nil
+	}
+	allowed := ok && contains(rule.Roles, role) && !adminCommands[command]
+	if allowed && command != "" && len(rule.Commands) > 0 {
+		allowed = contains(rule.Commands, command)
+	}
+	if allowed && Func != "" && len(rule.Funcs) > 0 {
+		allowed = false
+		for _, pattern := range rule.Funcs {
+			if matched, _ := path.Match(pattern, Func); matched {
+				allowed = true
+				break
+			}
+		}
+	}
+	if allowed {
+		return nil
+	}
+	sched.acl.denied[identity]++
+	log.Printf("ACL denied %q as %s: %s %s (%d denials)\n", identity, role, command, Func,
+		sched.acl.denied[identity])
+	return errPermissionDenied
+}
+
+// commandFunc returns the func the client command on
+func commandFunc(cmd protocol.Command, payload []byte) string {
+	switch cmd {
+	case protocol.SUBMITJOB, protocol.REMOVEJOB, REDRIVEJOB, PURGEJOB, GETRESULT, CANCELJOB:
+		job, _ := driver.Decode(payload)
+		return job.Func
+	case SUBMITWAIT:
+		if len(payload) < 8 {
+			return ""
+		}
+		job, _ := driver.Decode(payload[8:])
+		return job.Func
+	case protocol.DROPFUNC, CONFIGSET, CONFIGGET, LISTJOB, SUBSCRIBE, LISTWORKER, PAUSEFUNC, RESUMEFUNC:
+		Func, _ := decodeString(payload)
+		return Func
+	}
+	return ""
+}
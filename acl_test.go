@@ -0,0 +1,104 @@
+package periodic
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeACL(t *testing.T, data string) string {
+	dir, err := ioutil.TempDir("", "periodic-acl")
+	if err != nil {
+		t.Fatalf("TempDir: %s\n", err)
+	}
+	file := filepath.Join(dir, "acl.json")
+	if err = ioutil.WriteFile(file, []byte(data), 0600); err != nil {
+		t.Fatalf("WriteFile: %s\n", err)
+	}
+	return file
+}
+
+func TestLoadACL(t *testing.T) {
+	file := writeACL(t, `{"bot": {"roles": ["client"], "funcs": ["send_*"]}}`)
+	defer os.RemoveAll(filepath.Dir(file))
+	rules, err := loadACL(file)
+	if err != nil {
+		t.Fatalf("loadACL: %s\n", err)
+	}
+	if len(rules["bot"].Funcs) != 1 || rules["bot"].Funcs[0] != "send_*" {
+		t.Fatalf("loadACL: except: [send_*], got: %v\n", rules["bot"].Funcs)
+	}
+
+	for _, data := range []string{`{"bot": `, `{"bot": {"funcs": ["send_["]}}`} {
+		file := writeACL(t, data)
+		defer os.RemoveAll(filepath.Dir(file))
+		if _, err = loadACL(file); err == nil {
+			t.Fatalf("loadACL %s: except error, got nil\n", data)
+		}
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	file := writeACL(t, `{
+		"bot": {"roles": ["client"], "commands": ["SUBMITJOB", "STATUS"], "funcs": ["send_*"]},
+		"app": {"roles": ["client"]},
+		"sms": {"roles": ["worker"], "funcs": ["send_sms"]},
+		"ops": {"roles": ["admin"]}
+	}`)
+	defer os.RemoveAll(filepath.Dir(file))
+	sched := new(Sched)
+	if err := sched.SetACLFile(file); err != nil {
+		t.Fatalf("SetACLFile: %s\n", err)
+	}
+
+	var tests = []struct {
+		identity, role, command, Func string
+		allowed                       bool
+	}{
+		{"bot", "client", "", "", true},
+		{"bot", "client", "SUBMITJOB", "send_sms", true},
+		{"bot", "client", "SUBMITJOB", "other", false},
+		{"bot", "client", "REMOVEJOB", "send_sms", false},
+		{"bot", "worker", "", "", false},
+		{"app", "client", "REMOVEJOB", "any", true},
+		{"app", "client", "DROPFUNC", "any", false},
+		{"app", "client", "CONFIGSET", "any", false},
+		{"app", "client", "DRAINWORKER", "", false},
+		{"sms", "worker", "", "send_sms", true},
+		{"sms", "worker", "", "send_email", false},
+		{"ops", "worker", "", "any", true},
+		{"ops", "client", "DROPFUNC", "any", true},
+		{"nobody", "client", "STATUS", "", false},
+		{"", "client", "STATUS", "", false},
+	}
+	for _, test := range tests {
+		err := sched.authorize(test.identity, test.role, test.command, test.Func)
+		if (err == nil) != test.allowed {
+			t.Fatalf("authorize %v: except: %v, got: %v\n", test, test.allowed, err)
+		}
+	}
+
+	denials := sched.Denials()
+	if denials["bot"] != 3 || denials["app"] != 3 || denials["sms"] != 1 || denials["nobody"] != 1 || denials[""] != 1 {
+		t.Fatalf("Denials: except: bot:3 app:3 sms:1 nobody:1 :1, got: %v\n", denials)
+	}
+}
+
+func TestAuthorizeDefaultRule(t *testing.T) {
+	file := writeACL(t, `{"*": {"roles": ["client"], "commands": ["STATUS"]}}`)
+	defer os.RemoveAll(filepath.Dir(file))
+	sched := new(Sched)
+	if err := sched.SetACLFile(file); err != nil {
+		t.Fatalf("SetACLFile: %s\n", err)
+	}
+	if err := sched.authorize("", "client", "STATUS", ""); err != nil {
+		t.Fatalf("authorize: except: nil, got: %s\n", err)
+	}
+	if err := sched.authorize("anyone", "client", "SUBMITJOB", "f"); err != errPermissionDenied {
+		t.Fatalf("authorize: except: %s, got: %v\n", errPermissionDenied, err)
+	}
+	if err := (&Sched{}).authorize("anyone", "worker", "DROPFUNC", "f"); err != nil {
+		t.Fatalf("authorize without acl: except: nil, got: %s\n", err)
+	}
+}
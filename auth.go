@@ -0,0 +1,90 @@
+package periodic
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// authTokens defined the tokens to authenticate the connections, loaded from
+// a file of `<identity> <token>` lines. A line of only a token is a shared
+// secret with identity shared.
+type authTokens struct {
+	file   string
+	tokens map[string]string // token to identity
+	locker *sync.Mutex
+}
+
+func loadTokens(file string) (map[string]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var tokens = make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for lineno := 1; scanner.Scan(); lineno++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		switch len(fields) {
+		case 1:
+			tokens[fields[0]] = "shared"
+			break
+		case 2:
+			tokens[fields[1]] = fields[0]
+			break
+		default:
+			return nil, fmt.Errorf("%s:%d: invalid token line", file, lineno)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// SetAuthFile require the connections authenticated with the tokens in file
+func (sched *Sched) SetAuthFile(file string) error {
+	tokens, err := loadTokens(file)
+	if err != nil {
+		return err
+	}
+	sched.auth = &authTokens{
+		file:   file,
+		tokens: tokens,
+		locker: new(sync.Mutex),
+	}
+	return nil
+}
+
+// ReloadAuth load the token file again, the old tokens are kept on error.
+func (sched *Sched) ReloadAuth() error {
+	if sched.auth == nil {
+		return nil
+	}
+	tokens, err := loadTokens(sched.auth.file)
+	if err != nil {
+		return err
+	}
+	defer sched.auth.locker.Unlock()
+	sched.auth.locker.Lock()
+	sched.auth.tokens = tokens
+	return nil
+}
+
+// authenticate returns the identity of the token, every token is accepted
+// with an empty identity when auth is not required.
+func (sched *Sched) authenticate(token string) (identity string, ok bool) {
+	if sched.auth == nil {
+		return "", true
+	}
+	defer sched.auth.locker.Unlock()
+	sched.auth.locker.Lock()
+	identity, ok = sched.auth.tokens[strings.TrimSpace(token)]
+	return
+}
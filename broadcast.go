@@ -0,0 +1,214 @@
+package periodic
+
+import (
+	"encoding/json"
+	"github.com/Lupino/periodic/driver"
+	"sort"
+	"time"
+)
+
+// broadcast defined a broadcast job running on every worker of the func
+type broadcast struct {
+	job     driver.Job
+	pending map[*worker]bool // The workers not finished, true when the job is sent
+	results map[*worker]broadcastResult
+}
+
+// broadcastResult defined the status of a broadcast job on a worker
+type broadcastResult struct {
+	ID     string `json:"id"` // The registered id of the worker
+	Addr   string `json:"addr"`
+	Status string `json:"status"`
+}
+
+// submitBroadcast assign a copy of the job to every worker can do the func.
+// The workers without waiting grab receive the job when they grab, the
+// broadcast job is finished by its timeout when a worker never replies.
+func (sched *Sched) submitBroadcast(job driver.Job) bool {
+	defer sched.jobLocker.Unlock()
+	sched.jobLocker.Lock()
+	if _, ok := sched.procQueue[job.ID]; ok {
+		return true
+	}
+	if !sched.alive || sched.isPaused(job.Func) {
+		return false
+	}
+	if sched.reachConcurrency(sched.getFuncStat(job.Func)) {
+		return false
+	}
+	var workers = make([]*worker, 0)
+	sched.workerLocker.Lock()
+	for w := range sched.workers {
+		if (grabItem{w: w}).has(job) {
+			workers = append(workers, w)
+		}
+	}
+	sched.workerLocker.Unlock()
+	if len(workers) == 0 {
+		return false
+	}
+	if sched.rateLimitWait(job.Func) > 0 {
+		return false
+	}
+
+	job.SetProc()
+	job.RunAt = int64(time.Now().Unix())
+	job.LeaseUntil = 0
+	job.Progress = 0
+	job.ProgressMsg = ""
+	b := &broadcast{
+		job:     job,
+		pending: make(map[*worker]bool),
+		results: make(map[*worker]broadcastResult),
+	}
+	var sent = 0
+	for _, w := range workers {
+		b.pending[w] = sched.sendBroadcast(b, w)
+		if b.pending[w] {
+			sent++
+		}
+	}
+	if sent == 0 {
+		return false
+	}
+	sched.takeRateToken(job.Func)
+	sched.driver.Save(&job)
+	sched.incrStatProc(job)
+	sched.pushRevertPQ(job)
+	sched.notifyRevertTimer()
+	sched.procQueue[job.ID] = job
+	sched.broadcasts[job.ID] = b
+	return true
+}
+
+// sendBroadcast send the broadcast job to the waiting grab of the worker,
+// false when the worker has no waiting grab. The jobLocker must be held.
+func (sched *Sched) sendBroadcast(b *broadcast, w *worker) bool {
+	item, ok := sched.grabQueue.getWorker(w)
+	if !ok {
+		return false
+	}
+	if err := w.handleJobAssign(item.msgID, b.job); err != nil {
+		return false
+	}
+	sched.grabQueue.take(item)
+	sched.emit("assigned", b.job, w.name())
+	return true
+}
+
+// assignBroadcasts send the running broadcast jobs the worker is waiting for
+// to its grab.
+func (sched *Sched) assignBroadcasts(w *worker) {
+	defer sched.jobLocker.Unlock()
+	sched.jobLocker.Lock()
+	for _, b := range sched.broadcasts {
+		if sent, ok := b.pending[w]; !ok || sent {
+			continue
+		}
+		if !(grabItem{w: w}).has(b.job) {
+			continue
+		}
+		if !sched.sendBroadcast(b, w) {
+			return
+		}
+		b.pending[w] = true
+	}
+}
+
+// leaveBroadcasts fail the broadcast jobs not sent to the closed worker
+func (sched *Sched) leaveBroadcasts(w *worker) {
+	defer sched.jobLocker.Unlock()
+	sched.jobLocker.Lock()
+	for _, b := range sched.broadcasts {
+		if sent, ok := b.pending[w]; ok && !sent {
+			sched.recordBroadcast(b, w, "fail")
+		}
+	}
+}
+
+// recordBroadcast record the status of the broadcast job on the worker, the
+// broadcast job is finished when no worker is pending. The jobLocker must be
+// held.
+func (sched *Sched) recordBroadcast(b *broadcast, w *worker, status string) {
+	delete(b.pending, w)
+	b.results[w] = broadcastResult{
+		ID:     w.name(),
+		Addr:   w.conn.RemoteAddr().String(),
+		Status: status,
+	}
+	if status == "done" {
+		sched.emit("done", b.job, w.name())
+	} else {
+		sched.emit("failed", b.job, w.name())
+	}
+	if len(b.pending) == 0 {
+		sched.finishBroadcast(b, "")
+	}
+}
+
+// broadcastFinish record the status of the broadcast job on the worker,
+// returns false when the job is not a broadcast job. The status of a finished
+// broadcast job is dropped.
+func (sched *Sched) broadcastFinish(jobID int64, w *worker, status string) bool {
+	defer sched.jobLocker.Unlock()
+	sched.jobLocker.Lock()
+	b, ok := sched.broadcasts[jobID]
+	if !ok {
+		job, err := sched.driver.Get(jobID)
+		return err == nil && job.Broadcast
+	}
+	if !b.pending[w] {
+		return true
+	}
+	sched.recordBroadcast(b, w, status)
+	return true
+}
+
+// finishBroadcast report the broadcast job is finished on all the workers,
+// the still pending workers are marked with status. The broadcast job is done
+// when every worker is done, it is not retried on fail.
+// The jobLocker must be held.
+func (sched *Sched) finishBroadcast(b *broadcast, status string) {
+	defer sched.notifyJobTimer()
+	for w := range b.pending {
+		b.results[w] = broadcastResult{
+			ID:     w.name(),
+			Addr:   w.conn.RemoteAddr().String(),
+			Status: status,
+		}
+	}
+	job := b.job
+	delete(sched.broadcasts, job.ID)
+	if _, ok := sched.procQueue[job.ID]; ok {
+		delete(sched.procQueue, job.ID)
+	}
+	sched.decrStatProc(job)
+	sched.removeRevertPQ(job)
+	result := "done"
+	var results = make([]broadcastResult, 0, len(b.results))
+	for _, r := range b.results {
+		if r.Status != "done" {
+			result = "fail"
+		}
+		results = append(results, r)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].ID != results[j].ID {
+			return results[i].ID < results[j].ID
+		}
+		return results[i].Addr < results[j].Addr
+	})
+	data, _ := json.Marshal(results)
+	sched.saveResult(job, result, data)
+	sched.emit("broadcast_done", job, "")
+	if schedAt, ok := nextCronSchedAt(job); ok {
+		job.SetReady()
+		job.SchedAt = schedAt
+		job.Attempts = 0
+		sched.driver.Save(&job)
+		sched.pushJobPQ(job)
+		return
+	}
+	sched.driver.Delete(job.ID)
+	sched.decrStatJob(job)
+}
@@ -0,0 +1,63 @@
+package periodic
+
+import (
+	"encoding/json"
+	"github.com/Lupino/periodic/driver"
+	"testing"
+	"time"
+)
+
+func TestBroadcastWaitGrab(t *testing.T) {
+	sched := NewSched(nil, driver.NewMemStroeDriver(), 0)
+	defer func() {
+		sched.alive = false
+		sched.notifyJobTimer()
+	}()
+	w1, ch1 := newTestWorker(sched, "test")
+	w2, ch2 := newTestWorker(sched, "test")
+	// the workers registered with the same id
+	w1.handleRegister([]byte(`{"id":"dup"}`))
+	w2.handleRegister([]byte(`{"id":"dup"}`))
+	go sched.handleJobPQ()
+
+	w1.handleGrabJob([]byte("0001"), 1)
+	job := driver.Job{Func: "test", Name: "test-job", Broadcast: true, Timeout: 10}
+	if err := sched.addJob(&job); err != nil {
+		t.Fatalf("addJob: %s\n", err)
+	}
+	if name := waitAssigned(ch1, 2*time.Second, "test-job"); name == "" {
+		t.Fatalf("Assigned w1: except: test-job, got: nothing\n")
+	}
+	// the worker without waiting grab receive the job when it grabs
+	if name := waitAssigned(ch2, 300*time.Millisecond, "test-job"); name != "" {
+		t.Fatalf("Assigned w2 before grab: except: nothing, got: %s\n", name)
+	}
+	w2.handleGrabJob([]byte("0002"), 1)
+	if name := waitAssigned(ch2, 2*time.Second, "test-job"); name == "" {
+		t.Fatalf("Assigned w2: except: test-job, got: nothing\n")
+	}
+
+	w1.handleDone(job.ID, nil)
+	w2.handleFail(job.ID, nil)
+	r, err := sched.driver.GetResult("test", "test-job")
+	if err != nil {
+		t.Fatalf("GetResult: %s\n", err)
+	}
+	var results []broadcastResult
+	if err := json.Unmarshal([]byte(r.Data), &results); err != nil {
+		t.Fatalf("Unmarshal: %s\n", err)
+	}
+	if r.Status != "fail" || len(results) != 2 {
+		t.Fatalf("Result: except: fail with 2 workers, got: %s %s\n", r.Status, r.Data)
+	}
+	var status = make(map[string]int)
+	for _, result := range results {
+		if result.ID != "dup" {
+			t.Fatalf("Result id: except: dup, got: %s\n", result.ID)
+		}
+		status[result.Status]++
+	}
+	if status["done"] != 1 || status["fail"] != 1 {
+		t.Fatalf("Result status: except: 1 done 1 fail, got: %v\n", status)
+	}
+}
@@ -0,0 +1,57 @@
+package periodic
+
+import (
+	"fmt"
+	"github.com/Lupino/periodic/driver"
+)
+
+// deleteJob delete a job from the store and the queues, the worker running
+// the job is told to cancel it. The jobLocker must be held.
+func (sched *Sched) deleteJob(job driver.Job) {
+	if _, ok := sched.procQueue[job.ID]; ok {
+		delete(sched.procQueue, job.ID)
+	}
+	sched.driver.Delete(job.ID)
+	sched.decrStatJob(job)
+	if job.IsProc() {
+		sched.decrStatProc(job)
+		sched.removeRevertPQ(job)
+		for _, w := range sched.jobWorkers(job.ID) {
+			w.handleCancel(job)
+		}
+		delete(sched.broadcasts, job.ID)
+	}
+	sched.unparkJob(job)
+	sched.notifyJobTimer()
+}
+
+// cancelJob stop a processing job, the waiters get a canceled result
+func (sched *Sched) cancelJob(Func, name string) error {
+	defer sched.jobLocker.Unlock()
+	sched.jobLocker.Lock()
+	job, err := sched.driver.GetOne(Func, name)
+	if err != nil {
+		return err
+	}
+	if !job.IsProc() {
+		return fmt.Errorf("Job %s:%s is not processing.", Func, name)
+	}
+	sched.deleteJob(job)
+	sched.saveResult(job, "canceled", nil)
+	sched.emit("canceled", job, "")
+	return nil
+}
+
+// jobWorkers returns the workers the job assigned to, a broadcast job is
+// assigned to many workers.
+func (sched *Sched) jobWorkers(jobID int64) []*worker {
+	defer sched.workerLocker.Unlock()
+	sched.workerLocker.Lock()
+	var workers = make([]*worker, 0)
+	for w := range sched.workers {
+		if w.hasJob(jobID) {
+			workers = append(workers, w)
+		}
+	}
+	return workers
+}
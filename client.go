@@ -2,24 +2,43 @@ package periodic
 
 import (
 	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/Lupino/go-periodic/protocol"
 	"github.com/Lupino/periodic/driver"
 	"io"
 	"log"
+	"sync"
+	"time"
 )
 
 type client struct {
-	sched *Sched
-	conn  protocol.Conn
+	sched  *Sched
+	conn   protocol.Conn
+	locker *sync.Mutex
+	subs   []*subscriber
+	// identity authenticated on connect, empty when auth is not required
+	identity string
 }
 
 func newClient(sched *Sched, conn protocol.Conn) (c *client) {
 	c = new(client)
 	c.conn = conn
 	c.sched = sched
+	c.locker = new(sync.Mutex)
 	return
 }
 
+// send a message to the client, the replies of waiting jobs are sent from
+// other goroutines.
+func (c *client) send(data []byte) error {
+	defer c.locker.Unlock()
+	c.locker.Lock()
+	return c.conn.Send(data)
+}
+
 func (c *client) handle() {
 	var payload []byte
 	var err error
@@ -31,7 +50,11 @@ func (c *client) handle() {
 			log.Printf("[client] painc: %v\n", x)
 		}
 	}()
-	defer conn.Close()
+	defer c.close()
+	if e := c.sched.authorize(c.identity, "client", "", ""); e != nil {
+		c.send([]byte(e.Error()))
+		return
+	}
 	for {
 		payload, err = conn.Receive()
 		if err != nil {
@@ -42,11 +65,28 @@ func (c *client) handle() {
 		}
 
 		msgID, cmd, payload = protocol.ParseCommand(payload)
+		// the func is decoded once, the same one is authorized and handled
+		Func := commandFunc(cmd, payload)
+
+		if c.sched.acl != nil {
+			if e := c.sched.authorize(c.identity, "client", commandNames[cmd], Func); e != nil {
+				if err = c.handleError(msgID, e); err != nil {
+					return
+				}
+				continue
+			}
+		}
 
 		switch cmd {
 		case protocol.SUBMITJOB:
 			err = c.handleSubmitJob(msgID, payload)
 			break
+		case SUBMITWAIT:
+			err = c.handleSubmitWait(msgID, payload)
+			break
+		case SUBSCRIBE:
+			err = c.handleSubscribe(msgID, payload)
+			break
 		case protocol.STATUS:
 			err = c.handleStatus(msgID)
 			break
@@ -54,11 +94,44 @@ func (c *client) handle() {
 			err = c.handleCommand(msgID, protocol.PONG)
 			break
 		case protocol.DROPFUNC:
-			err = c.handleDropFunc(msgID, payload)
+			err = c.handleDropFunc(msgID, Func)
 			break
 		case protocol.REMOVEJOB:
 			err = c.handleRemoveJob(msgID, payload)
 			break
+		case CONFIGSET:
+			err = c.handleConfigSet(msgID, payload)
+			break
+		case CONFIGGET:
+			err = c.handleConfigGet(msgID, payload)
+			break
+		case LISTJOB:
+			err = c.handleListJob(msgID, payload)
+			break
+		case REDRIVEJOB:
+			err = c.handleJobAction(msgID, payload, c.sched.redriveJob)
+			break
+		case PURGEJOB:
+			err = c.handleJobAction(msgID, payload, c.sched.purgeJob)
+			break
+		case CANCELJOB:
+			err = c.handleJobAction(msgID, payload, c.sched.cancelJob)
+			break
+		case PAUSEFUNC:
+			err = c.handleFuncAction(msgID, payload, c.sched.PauseFunc)
+			break
+		case RESUMEFUNC:
+			err = c.handleFuncAction(msgID, payload, c.sched.ResumeFunc)
+			break
+		case DRAINWORKER:
+			err = c.handleDrainWorker(msgID, payload)
+			break
+		case LISTWORKER:
+			err = c.handleListWorker(msgID, payload)
+			break
+		case GETRESULT:
+			err = c.handleGetResult(msgID, payload)
+			break
 		default:
 			err = c.handleCommand(msgID, protocol.UNKNOWN)
 			break
@@ -72,54 +145,75 @@ func (c *client) handle() {
 	}
 }
 
+func (c *client) close() {
+	for _, sub := range c.subs {
+		c.sched.unsubscribe(sub)
+	}
+	c.conn.Close()
+}
+
 func (c *client) handleCommand(msgID []byte, cmd protocol.Command) (err error) {
 	buf := bytes.NewBuffer(nil)
 	buf.Write(msgID)
 	buf.Write(cmd.Bytes())
-	err = c.conn.Send(buf.Bytes())
+	err = c.send(buf.Bytes())
+	return
+}
+
+func (c *client) handleError(msgID []byte, e error) (err error) {
+	buf := bytes.NewBuffer(nil)
+	buf.Write(msgID)
+	buf.WriteString(e.Error())
+	err = c.send(buf.Bytes())
 	return
 }
 
 func (c *client) handleSubmitJob(msgID []byte, payload []byte) (err error) {
 	var job driver.Job
 	var e error
-	var conn = c.conn
-	var sched = c.sched
-	defer sched.jobLocker.Unlock()
-	sched.jobLocker.Lock()
 	job, e = driver.Decode(payload)
+	if e == nil {
+		e = c.sched.addJob(&job)
+	}
 	if e != nil {
-		err = conn.Send([]byte(e.Error()))
+		err = c.send([]byte(e.Error()))
 		return
 	}
-	isNew := true
-	changed := false
-	job.SetReady()
-	oldJob, e := sched.driver.GetOne(job.Func, job.Name)
-	if e == nil && oldJob.ID > 0 {
-		job.ID = oldJob.ID
-		if oldJob.IsProc() {
-			sched.decrStatProc(oldJob)
-			sched.removeRevertPQ(job)
-			changed = true
-		}
-		isNew = false
+	err = c.handleCommand(msgID, protocol.SUCCESS)
+	return
+}
+
+func (c *client) handleSubmitWait(msgID []byte, payload []byte) (err error) {
+	if len(payload) < 8 {
+		return c.handleError(msgID, errors.New("wait timeout is required"))
 	}
-	e = sched.driver.Save(&job)
+	timeout := time.Duration(binary.BigEndian.Uint64(payload[0:8])) * time.Second
+	job, e := driver.Decode(payload[8:])
 	if e != nil {
-		err = conn.Send([]byte(e.Error()))
-		return
+		return c.handleError(msgID, e)
 	}
-
-	if isNew {
-		sched.incrStatJob(job)
+	ch, e := c.sched.addWaitJob(&job)
+	if e != nil {
+		return c.handleError(msgID, e)
 	}
-	if isNew || changed {
-		sched.pushJobPQ(job)
+	// a zero timeout wait until the job is done or failed
+	var timer <-chan time.Time
+	if timeout > 0 {
+		timer = time.After(timeout)
 	}
-	sched.notifyJobTimer()
-	err = c.handleCommand(msgID, protocol.SUCCESS)
-	return
+	go func() {
+		select {
+		case r := <-ch:
+			buf := bytes.NewBuffer(nil)
+			buf.Write(msgID)
+			buf.Write(r.Bytes())
+			c.send(buf.Bytes())
+		case <-timer:
+			c.sched.removeWaiter(job.ID, ch)
+			c.handleError(msgID, fmt.Errorf("Job %s:%s wait timeout.", job.Func, job.Name))
+		}
+	}()
+	return nil
 }
 
 func (c *client) handleStatus(msgID []byte) (err error) {
@@ -131,12 +225,11 @@ func (c *client) handleStatus(msgID []byte) (err error) {
 		buf.WriteString(stat.String())
 		buf.WriteString("\n")
 	}
-	err = c.conn.Send(buf.Bytes())
+	err = c.send(buf.Bytes())
 	return
 }
 
-func (c *client) handleDropFunc(msgID []byte, payload []byte) (err error) {
-	Func := string(payload[1:])
+func (c *client) handleDropFunc(msgID []byte, Func string) (err error) {
 	sched := c.sched
 	defer sched.notifyJobTimer()
 	defer sched.jobLocker.Unlock()
@@ -146,13 +239,16 @@ func (c *client) handleDropFunc(msgID []byte, payload []byte) (err error) {
 	sched.funcLocker.Lock()
 	stat, ok := sched.stats[Func]
 	if ok && stat.Worker.Int() == 0 {
-		iter := sched.driver.NewIterator(payload)
+		iter := sched.driver.NewIterator([]byte(Func))
 		var deleteJob = make([]int64, 0)
 		for {
 			if !iter.Next() {
 				break
 			}
 			job := iter.Value()
+			if job.Func != Func {
+				continue
+			}
 			deleteJob = append(deleteJob, job.ID)
 		}
 		iter.Close()
@@ -161,6 +257,7 @@ func (c *client) handleDropFunc(msgID []byte, payload []byte) (err error) {
 		}
 		delete(sched.stats, Func)
 		delete(sched.jobPQ, Func)
+		delete(sched.readyPQ, Func)
 	}
 	err = c.handleCommand(msgID, protocol.SUCCESS)
 	return
@@ -169,33 +266,130 @@ func (c *client) handleDropFunc(msgID []byte, payload []byte) (err error) {
 func (c *client) handleRemoveJob(msgID, payload []byte) (err error) {
 	var job driver.Job
 	var e error
-	var conn = c.conn
 	var sched = c.sched
 	defer sched.jobLocker.Unlock()
 	sched.jobLocker.Lock()
 	job, e = driver.Decode(payload)
 	if e != nil {
-		err = conn.Send([]byte(e.Error()))
+		err = c.send([]byte(e.Error()))
 		return
 	}
 	job, e = sched.driver.GetOne(job.Func, job.Name)
 	if e == nil && job.ID > 0 {
-		if _, ok := sched.procQueue[job.ID]; ok {
-			delete(sched.procQueue, job.ID)
-		}
-		sched.driver.Delete(job.ID)
-		sched.decrStatJob(job)
-		if job.IsProc() {
-			sched.decrStatProc(job)
-			sched.removeRevertPQ(job)
-		}
-		sched.notifyJobTimer()
+		sched.deleteJob(job)
+		sched.emit("removed", job, "")
 	}
 
 	if e != nil {
-		err = conn.Send([]byte(e.Error()))
+		err = c.send([]byte(e.Error()))
 	} else {
 		err = c.handleCommand(msgID, protocol.SUCCESS)
 	}
 	return
 }
+
+func (c *client) handleConfigSet(msgID, payload []byte) (err error) {
+	Func, payload := decodeString(payload)
+	key, payload := decodeString(payload)
+	if e := c.sched.setFuncConfig(Func, key, string(payload)); e != nil {
+		return c.handleError(msgID, e)
+	}
+	err = c.handleCommand(msgID, protocol.SUCCESS)
+	return
+}
+
+func (c *client) handleConfigGet(msgID, payload []byte) (err error) {
+	Func, _ := decodeString(payload)
+	data, _ := json.Marshal(c.sched.getFuncConfig(Func))
+	buf := bytes.NewBuffer(nil)
+	buf.Write(msgID)
+	buf.Write(data)
+	err = c.send(buf.Bytes())
+	return
+}
+
+func (c *client) handleListJob(msgID, payload []byte) (err error) {
+	Func, payload := decodeString(payload)
+	data, _ := json.Marshal(c.sched.listJobs(Func, string(payload)))
+	buf := bytes.NewBuffer(nil)
+	buf.Write(msgID)
+	buf.Write(data)
+	err = c.send(buf.Bytes())
+	return
+}
+
+func (c *client) handleListWorker(msgID, payload []byte) (err error) {
+	Func, _ := decodeString(payload)
+	data, _ := json.Marshal(c.sched.listWorkers(Func))
+	buf := bytes.NewBuffer(nil)
+	buf.Write(msgID)
+	buf.Write(data)
+	err = c.send(buf.Bytes())
+	return
+}
+
+func (c *client) handleFuncAction(msgID, payload []byte, action func(string) error) (err error) {
+	Func, _ := decodeString(payload)
+	if e := action(Func); e != nil {
+		return c.handleError(msgID, e)
+	}
+	err = c.handleCommand(msgID, protocol.SUCCESS)
+	return
+}
+
+func (c *client) handleDrainWorker(msgID, payload []byte) (err error) {
+	name, _ := decodeString(payload)
+	if e := c.sched.drainWorkers(name); e != nil {
+		return c.handleError(msgID, e)
+	}
+	err = c.handleCommand(msgID, protocol.SUCCESS)
+	return
+}
+
+func (c *client) handleJobAction(msgID, payload []byte, action func(string, string) error) (err error) {
+	job, e := driver.Decode(payload)
+	if e == nil {
+		e = action(job.Func, job.Name)
+	}
+	if e != nil {
+		return c.handleError(msgID, e)
+	}
+	err = c.handleCommand(msgID, protocol.SUCCESS)
+	return
+}
+
+func (c *client) handleGetResult(msgID, payload []byte) (err error) {
+	job, e := driver.Decode(payload)
+	if e != nil {
+		return c.handleError(msgID, e)
+	}
+	r, e := c.sched.jobResult(job.Func, job.Name)
+	if e != nil {
+		return c.handleError(msgID, e)
+	}
+	buf := bytes.NewBuffer(nil)
+	buf.Write(msgID)
+	buf.Write(r.Bytes())
+	err = c.send(buf.Bytes())
+	return
+}
+
+func (c *client) handleSubscribe(msgID, payload []byte) (err error) {
+	Func, _ := decodeString(payload)
+	if err = c.handleCommand(msgID, protocol.SUCCESS); err != nil {
+		return
+	}
+	sub := c.sched.subscribe(Func)
+	c.subs = append(c.subs, sub)
+	go func() {
+		for ev := range sub.ch {
+			buf := bytes.NewBuffer(nil)
+			buf.Write(msgID)
+			buf.Write(ev.Bytes())
+			if err := c.send(buf.Bytes()); err != nil {
+				c.sched.unsubscribe(sub)
+			}
+		}
+	}()
+	return
+}
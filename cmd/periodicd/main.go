@@ -11,6 +11,8 @@ import (
 	"os/signal"
 	"runtime"
 	"runtime/pprof"
+	"strings"
+	"syscall"
 	"time"
 )
 
@@ -23,7 +25,7 @@ func main() {
 		cli.StringFlag{
 			Name:   "H",
 			Value:  "unix:///tmp/periodic.sock",
-			Usage:  "the server address eg: tcp://127.0.0.1:5000",
+			Usage:  "the server addresses split by comma before each scheme eg: unix:///tmp/periodic.sock,tcp://127.0.0.1:5000?timeout=30&tls=true&auth=false",
 			EnvVar: "PERIODIC_PORT",
 		},
 		cli.StringFlag{
@@ -46,6 +48,46 @@ func main() {
 			Value: 0,
 			Usage: "The socket timeout",
 		},
+		cli.StringFlag{
+			Name:  "dispatch",
+			Value: "earliest",
+			Usage: "The dispatch policy across funcs [earliest, roundrobin, wfq]",
+		},
+		cli.IntFlag{
+			Name:  "result-retention",
+			Value: 3600,
+			Usage: "The seconds to keep the job results, 0 to not keep",
+		},
+		cli.StringFlag{
+			Name:  "tls-cert",
+			Value: "",
+			Usage: "The server certificate file, serve the tcp entry point with TLS",
+		},
+		cli.StringFlag{
+			Name:  "tls-key",
+			Value: "",
+			Usage: "The server private key file",
+		},
+		cli.StringFlag{
+			Name:  "tls-ca",
+			Value: "",
+			Usage: "The ca file to verify the client certificates, empty to not require",
+		},
+		cli.StringFlag{
+			Name:  "auth-file",
+			Value: "",
+			Usage: "The token file, each line is `<identity> <token>` or a shared token, empty to not require auth",
+		},
+		cli.StringFlag{
+			Name:  "acl-file",
+			Value: "",
+			Usage: "The json file of the identities to the allowed roles, commands and funcs",
+		},
+		cli.IntFlag{
+			Name:  "shutdown-timeout",
+			Value: 30,
+			Usage: "The seconds to wait the processing jobs on shutdown, a second signal exit immediately",
+		},
 		cli.IntFlag{
 			Name:   "cpus",
 			Value:  runtime.NumCPU(),
@@ -85,14 +127,84 @@ func main() {
 
 		runtime.GOMAXPROCS(c.Int("cpus"))
 		timeout := time.Duration(c.Int("timeout"))
-		periodicd := periodic.NewSched(c.String("H"), store, timeout)
+		periodicd := periodic.NewSched(splitEntryPoints(c.String("H")), store, timeout)
+		if err := periodicd.SetDispatchPolicy(c.String("dispatch")); err != nil {
+			log.Fatal(err)
+		}
+		periodicd.SetResultRetention(time.Duration(c.Int("result-retention")) * time.Second)
+		periodicd.SetShutdownTimeout(time.Duration(c.Int("shutdown-timeout")) * time.Second)
+		if c.String("tls-cert") != "" {
+			if err := periodicd.SetTLS(c.String("tls-cert"), c.String("tls-key"), c.String("tls-ca")); err != nil {
+				log.Fatal(err)
+			}
+		}
+		if c.String("auth-file") != "" {
+			if err := periodicd.SetAuthFile(c.String("auth-file")); err != nil {
+				log.Fatal(err)
+			}
+		}
+		if c.String("acl-file") != "" {
+			if err := periodicd.SetACLFile(c.String("acl-file")); err != nil {
+				log.Fatal(err)
+			}
+		}
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		go func() {
+			for range hup {
+				if err := periodicd.ReloadTLS(); err != nil {
+					log.Printf("Reload certificate error: %v\n", err)
+				}
+				if err := periodicd.ReloadAuth(); err != nil {
+					log.Printf("Reload auth file error: %v\n", err)
+				}
+				if err := periodicd.ReloadACL(); err != nil {
+					log.Printf("Reload acl file error: %v\n", err)
+				}
+				log.Printf("Periodic task system reloaded\n")
+			}
+		}()
 		go periodicd.Serve()
-		s := make(chan os.Signal, 1)
-		signal.Notify(s, os.Interrupt, os.Kill)
+		s := make(chan os.Signal, 2)
+		signal.Notify(s, os.Interrupt, syscall.SIGTERM)
 		<-s
+		go func() {
+			<-s
+			log.Printf("Periodic task system force shutdown\n")
+			os.Exit(1)
+		}()
 		periodicd.Close()
 		return nil
 	}
 
 	app.Run(os.Args)
 }
+
+// splitEntryPoints split the entry points by the comma followed by a scheme,
+// the other commas are kept in the entry point.
+func splitEntryPoints(value string) []string {
+	var entryPoints = make([]string, 0)
+	for _, part := range strings.Split(value, ",") {
+		if len(entryPoints) > 0 && !hasScheme(part) {
+			entryPoints[len(entryPoints)-1] += "," + part
+			continue
+		}
+		entryPoints = append(entryPoints, part)
+	}
+	return entryPoints
+}
+
+// hasScheme check the entry point start with a scheme like tcp://
+func hasScheme(entryPoint string) bool {
+	entryPoint = strings.TrimSpace(entryPoint)
+	i := strings.Index(entryPoint, "://")
+	if i <= 0 {
+		return false
+	}
+	for _, c := range entryPoint[:i] {
+		if c < 'a' || c > 'z' {
+			return false
+		}
+	}
+	return true
+}
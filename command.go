@@ -0,0 +1,63 @@
+package periodic
+
+import (
+	"github.com/Lupino/go-periodic/protocol"
+)
+
+// The commands periodic supported beyond the go-periodic protocol, numbered
+// after protocol.REMOVEJOB.
+const (
+	// CONFIGSET set a config of a func
+	CONFIGSET protocol.Command = protocol.REMOVEJOB + iota + 1 // client
+	// CONFIGGET get the configs of a func
+	CONFIGGET // client
+	// LISTJOB list the jobs of a func, filter by status
+	LISTJOB // client
+	// REDRIVEJOB move dead jobs back to ready
+	REDRIVEJOB // client
+	// PURGEJOB delete dead jobs
+	PURGEJOB // client
+	// GETRESULT get the result of a job
+	GETRESULT // client
+	// SUBMITWAIT submit a job and wait until it is done or failed, the
+	// payload is the timeout seconds as uint64 and the job, 0 is no timeout
+	SUBMITWAIT // client
+	// SUBSCRIBE receive the job events of a func or all the funcs
+	SUBSCRIBE // client
+	// EXTENDLEASE tell server the job is still running, extend its timeout
+	EXTENDLEASE // worker
+	// HEARTBEAT tell server the interval the worker sends messages in
+	HEARTBEAT // worker
+	// PROGRESS report the progress of a job, the percentage and a message
+	PROGRESS // worker
+	// CANCELJOB cancel a processing job, server tell the worker running it
+	// with the same command and the job handle
+	CANCELJOB // client
+	// CANCELACK worker acknowledge the job is canceled
+	CANCELACK // worker
+	// LABELS set the labels of the worker with a json object
+	LABELS // worker
+	// REGISTER set the id, hostname and version of the worker
+	REGISTER // worker
+	// LISTWORKER list the connected workers of a func or all the workers
+	LISTWORKER // client
+	// DRAINWORKER stop assigning jobs to the workers with the id or hostname,
+	// they are closed after the running jobs finished
+	DRAINWORKER // client
+	// PAUSEFUNC stop dispatching the jobs of a func
+	PAUSEFUNC // client
+	// RESUMEFUNC dispatch the jobs of a paused func again
+	RESUMEFUNC // client
+)
+
+// decodeString read a string prefixed with one byte length from the payload.
+func decodeString(payload []byte) (str string, rest []byte) {
+	if len(payload) == 0 {
+		return
+	}
+	size := int(payload[0])
+	if size > len(payload)-1 {
+		size = len(payload) - 1
+	}
+	return string(payload[1 : size+1]), payload[size+1:]
+}
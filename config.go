@@ -0,0 +1,280 @@
+package periodic
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/Lupino/periodic/driver"
+	"github.com/Lupino/periodic/ratelimit"
+	"github.com/Lupino/periodic/stat"
+	"log"
+	"strconv"
+	"time"
+)
+
+// funcConfig defined the settings of a func
+type funcConfig struct {
+	Retry          *driver.RetryPolicy `json:"retry"`           // The default retry policy of the func jobs
+	MaxConcurrency int                 `json:"max_concurrency"` // The max processing jobs of the func, 0 is unlimited
+	RateLimit      string              `json:"rate_limit"`      // The max dispatched jobs per duration of the func, eg: 100/m
+	Weight         int                 `json:"weight"`          // The share of the func in weighted fair dispatch, 0 is 1
+	Paused         bool                `json:"paused"`          // The jobs of the paused func are not dispatched
+	bucket         *ratelimit.Bucket
+}
+
+func (sched *Sched) getFuncConfig(Func string) funcConfig {
+	defer sched.configLocker.Unlock()
+	sched.configLocker.Lock()
+	if cfg, ok := sched.configs[Func]; ok {
+		return *cfg
+	}
+	return funcConfig{}
+}
+
+func (sched *Sched) updateFuncConfig(Func string, update func(*funcConfig)) {
+	defer sched.configLocker.Unlock()
+	sched.configLocker.Lock()
+	cfg, ok := sched.configs[Func]
+	if !ok {
+		cfg = new(funcConfig)
+		sched.configs[Func] = cfg
+	}
+	update(cfg)
+}
+
+// storeFuncConfig update the config of a func and persist it, the config is
+// not changed when the store fail. The paused state is persisted alone.
+func (sched *Sched) storeFuncConfig(Func string, update func(*funcConfig)) error {
+	defer sched.configLocker.Unlock()
+	sched.configLocker.Lock()
+	var cfg funcConfig
+	if old, ok := sched.configs[Func]; ok {
+		cfg = *old
+	}
+	update(&cfg)
+	var data []byte
+	if cfg.Retry != nil || cfg.MaxConcurrency > 0 || cfg.RateLimit != "" || cfg.Weight > 0 {
+		data, _ = json.Marshal(storedConfig{
+			Retry:          cfg.Retry,
+			MaxConcurrency: cfg.MaxConcurrency,
+			RateLimit:      cfg.RateLimit,
+			Weight:         cfg.Weight,
+		})
+	}
+	if err := sched.driver.SetFuncConfig(Func, data); err != nil {
+		return err
+	}
+	sched.configs[Func] = &cfg
+	return nil
+}
+
+// storedConfig defined the persisted settings of a func
+type storedConfig struct {
+	Retry          *driver.RetryPolicy `json:"retry,omitempty"`
+	MaxConcurrency int                 `json:"max_concurrency,omitempty"`
+	RateLimit      string              `json:"rate_limit,omitempty"`
+	Weight         int                 `json:"weight,omitempty"`
+}
+
+// loadFuncConfigs restore the func configs from the store
+func (sched *Sched) loadFuncConfigs() {
+	configs, err := sched.driver.FuncConfigs()
+	if err != nil {
+		log.Printf("Load func configs error: %v\n", err)
+		return
+	}
+	for Func, data := range configs {
+		var stored storedConfig
+		if err := json.Unmarshal(data, &stored); err != nil {
+			log.Printf("Load func %s config error: %v\n", Func, err)
+			continue
+		}
+		var bucket *ratelimit.Bucket
+		if stored.RateLimit != "" {
+			if bucket, err = ratelimit.Parse(stored.RateLimit); err != nil {
+				log.Printf("Load func %s rate limit error: %v\n", Func, err)
+				stored.RateLimit = ""
+			}
+		}
+		sched.updateFuncConfig(Func, func(cfg *funcConfig) {
+			cfg.Retry = stored.Retry
+			cfg.MaxConcurrency = stored.MaxConcurrency
+			cfg.RateLimit = stored.RateLimit
+			cfg.bucket = bucket
+			cfg.Weight = stored.Weight
+		})
+	}
+}
+
+// SetRetryPolicy set the default retry policy of a func, nil to remove it.
+func (sched *Sched) SetRetryPolicy(Func string, policy *driver.RetryPolicy) error {
+	return sched.storeFuncConfig(Func, func(cfg *funcConfig) {
+		cfg.Retry = policy
+	})
+}
+
+// SetMaxConcurrency set the max processing jobs of a func, 0 is unlimited.
+func (sched *Sched) SetMaxConcurrency(Func string, limit int) error {
+	if err := sched.storeFuncConfig(Func, func(cfg *funcConfig) {
+		cfg.MaxConcurrency = limit
+	}); err != nil {
+		return err
+	}
+	sched.notifyJobTimer()
+	return nil
+}
+
+// SetRateLimit set the max dispatched jobs per duration of a func like 100/m,
+// 10/s or 1000/h, empty to remove it.
+func (sched *Sched) SetRateLimit(Func, spec string) error {
+	var bucket *ratelimit.Bucket
+	var err error
+	if spec != "" {
+		if bucket, err = ratelimit.Parse(spec); err != nil {
+			return err
+		}
+	}
+	if err = sched.storeFuncConfig(Func, func(cfg *funcConfig) {
+		cfg.RateLimit = spec
+		cfg.bucket = bucket
+	}); err != nil {
+		return err
+	}
+	sched.notifyJobTimer()
+	return nil
+}
+
+// SetWeight set the share of a func in weighted fair dispatch.
+func (sched *Sched) SetWeight(Func string, weight int) error {
+	return sched.storeFuncConfig(Func, func(cfg *funcConfig) {
+		cfg.Weight = weight
+	})
+}
+
+// PauseFunc stop dispatching the jobs of a func, the workers keep connected
+// and the jobs are kept. The paused state is persisted.
+func (sched *Sched) PauseFunc(Func string) error {
+	return sched.setPaused(Func, true)
+}
+
+// ResumeFunc dispatch the jobs of a paused func again.
+func (sched *Sched) ResumeFunc(Func string) error {
+	return sched.setPaused(Func, false)
+}
+
+func (sched *Sched) setPaused(Func string, paused bool) error {
+	if Func == "" {
+		return fmt.Errorf("func is required")
+	}
+	if err := sched.driver.SetPaused(Func, paused); err != nil {
+		return err
+	}
+	sched.updateFuncConfig(Func, func(cfg *funcConfig) {
+		cfg.Paused = paused
+	})
+	sched.notifyJobTimer()
+	return nil
+}
+
+func (sched *Sched) isPaused(Func string) bool {
+	return sched.getFuncConfig(Func).Paused
+}
+
+// loadPausedFuncs restore the paused funcs from the store
+func (sched *Sched) loadPausedFuncs() {
+	funcs, err := sched.driver.PausedFuncs()
+	if err != nil {
+		log.Printf("Load paused funcs error: %v\n", err)
+		return
+	}
+	for _, Func := range funcs {
+		sched.updateFuncConfig(Func, func(cfg *funcConfig) {
+			cfg.Paused = true
+		})
+	}
+}
+
+// setFuncConfig set a config of a func from its string value
+func (sched *Sched) setFuncConfig(Func, key, value string) error {
+	if Func == "" {
+		return fmt.Errorf("func is required")
+	}
+	switch key {
+	case "retry":
+		if value == "" {
+			return sched.SetRetryPolicy(Func, nil)
+		}
+		var policy driver.RetryPolicy
+		if err := json.Unmarshal([]byte(value), &policy); err != nil {
+			return err
+		}
+		return sched.SetRetryPolicy(Func, &policy)
+	case "max_concurrency":
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			return fmt.Errorf("invalid max_concurrency: %s", value)
+		}
+		return sched.SetMaxConcurrency(Func, limit)
+	case "rate_limit":
+		return sched.SetRateLimit(Func, value)
+	case "weight":
+		weight, err := strconv.Atoi(value)
+		if err != nil || weight < 0 {
+			return fmt.Errorf("invalid weight: %s", value)
+		}
+		return sched.SetWeight(Func, weight)
+	default:
+		return fmt.Errorf("unknown config: %s", key)
+	}
+}
+
+// retryPolicy returns the retry policy of the job or its func default
+func (sched *Sched) retryPolicy(job driver.Job) *driver.RetryPolicy {
+	if job.Retry != nil {
+		return job.Retry
+	}
+	return sched.getFuncConfig(job.Func).Retry
+}
+
+// reachConcurrency check the processing jobs of func reach its max concurrency
+func (sched *Sched) reachConcurrency(st *stat.FuncStat) bool {
+	limit := sched.getFuncConfig(st.Name).MaxConcurrency
+	if limit <= 0 {
+		return false
+	}
+	return st.Processing.Int() >= limit
+}
+
+// rateLimitWait returns the duration until func can dispatch a job, 0 when
+// the func is not rate limited.
+func (sched *Sched) rateLimitWait(Func string) time.Duration {
+	bucket := sched.getFuncConfig(Func).bucket
+	if bucket == nil {
+		return 0
+	}
+	return bucket.Wait(time.Now())
+}
+
+// takeRateToken take a dispatch token of func, false when the func is rate
+// limited. Check the token with rateLimitWait before dispatching, the token is
+// only taken once the job is sent.
+func (sched *Sched) takeRateToken(Func string) bool {
+	bucket := sched.getFuncConfig(Func).bucket
+	if bucket == nil {
+		return true
+	}
+	return bucket.Take(time.Now())
+}
+
+// rateLimitState returns the rate limit and the available tokens of func,
+// false when the func is not rate limited.
+func (sched *Sched) rateLimitState(Func string) (string, int, bool) {
+	bucket := sched.getFuncConfig(Func).bucket
+	if bucket == nil {
+		return "", 0, false
+	}
+	return bucket.String(), bucket.Tokens(time.Now()), true
+}
+
+func (sched *Sched) funcWeight(Func string) int {
+	return sched.getFuncConfig(Func).Weight
+}
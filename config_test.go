@@ -0,0 +1,60 @@
+package periodic
+
+import (
+	"github.com/Lupino/periodic/driver"
+	"testing"
+)
+
+func TestFuncConfigPersist(t *testing.T) {
+	store := driver.NewMemStroeDriver()
+	sched := NewSched([]string{"unix:///tmp/periodic.sock"}, store, 0)
+	var settings = [][2]string{
+		{"retry", `{"max_attempts":5,"base_delay":1}`},
+		{"max_concurrency", "10"},
+		{"rate_limit", "100/m"},
+		{"weight", "3"},
+	}
+	for _, s := range settings {
+		if err := sched.setFuncConfig("test", s[0], s[1]); err != nil {
+			t.Fatalf("setFuncConfig %s: %s\n", s[0], err)
+		}
+	}
+	if err := sched.PauseFunc("test"); err != nil {
+		t.Fatalf("PauseFunc: %s\n", err)
+	}
+
+	sched = NewSched([]string{"unix:///tmp/periodic.sock"}, store, 0)
+	sched.loadFuncConfigs()
+	sched.loadPausedFuncs()
+	cfg := sched.getFuncConfig("test")
+	if cfg.Retry == nil || cfg.Retry.MaxAttempts != 5 || cfg.Retry.BaseDelay != 1 {
+		t.Fatalf("Retry: except: 5 attempts 1 delay, got: %v\n", cfg.Retry)
+	}
+	if cfg.MaxConcurrency != 10 {
+		t.Fatalf("MaxConcurrency: except: 10, got: %d\n", cfg.MaxConcurrency)
+	}
+	if cfg.RateLimit != "100/m" || cfg.bucket == nil {
+		t.Fatalf("RateLimit: except: 100/m, got: %s\n", cfg.RateLimit)
+	}
+	if cfg.Weight != 3 {
+		t.Fatalf("Weight: except: 3, got: %d\n", cfg.Weight)
+	}
+	if !cfg.Paused {
+		t.Fatalf("Paused: except: true, got: false\n")
+	}
+
+	var resets = [][2]string{
+		{"retry", ""},
+		{"max_concurrency", "0"},
+		{"rate_limit", ""},
+		{"weight", "0"},
+	}
+	for _, s := range resets {
+		if err := sched.setFuncConfig("test", s[0], s[1]); err != nil {
+			t.Fatalf("setFuncConfig %s: %s\n", s[0], err)
+		}
+	}
+	if configs, _ := store.FuncConfigs(); len(configs) != 0 {
+		t.Fatalf("FuncConfigs: except: 0, got: %d\n", len(configs))
+	}
+}
@@ -0,0 +1,196 @@
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule defined a parsed cron expression
+type Schedule struct {
+	minute uint64
+	hour   uint64
+	dom    uint64
+	month  uint64
+	dow    uint64
+	// domStar or dowStar is true when the field is a plain `*`
+	domStar bool
+	dowStar bool
+	every   time.Duration
+}
+
+type bounds struct {
+	min   int
+	max   int
+	names map[string]int
+}
+
+var (
+	minuteBounds = bounds{0, 59, nil}
+	hourBounds   = bounds{0, 23, nil}
+	domBounds    = bounds{1, 31, nil}
+	monthBounds  = bounds{1, 12, map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	dowBounds = bounds{0, 7, map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Parse a standard five fields cron expression (minute hour day-of-month
+// month day-of-week), one of the @yearly, @monthly, @weekly, @daily, @hourly
+// descriptors or `@every <duration>`.
+func Parse(expr string) (sched *Schedule, err error) {
+	expr = strings.TrimSpace(expr)
+	if strings.HasPrefix(expr, "@every ") {
+		var d time.Duration
+		d, err = time.ParseDuration(strings.TrimSpace(expr[7:]))
+		if err != nil {
+			return
+		}
+		if d < time.Second {
+			err = fmt.Errorf("cron: @every duration %s is less than one second", d)
+			return
+		}
+		sched = &Schedule{every: d}
+		return
+	}
+	if spec, ok := descriptors[expr]; ok {
+		expr = spec
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		err = fmt.Errorf("cron: expected 5 fields, got %d: %q", len(fields), expr)
+		return
+	}
+	sched = new(Schedule)
+	if sched.minute, err = parseField(fields[0], minuteBounds); err != nil {
+		return nil, err
+	}
+	if sched.hour, err = parseField(fields[1], hourBounds); err != nil {
+		return nil, err
+	}
+	if sched.dom, err = parseField(fields[2], domBounds); err != nil {
+		return nil, err
+	}
+	if sched.month, err = parseField(fields[3], monthBounds); err != nil {
+		return nil, err
+	}
+	if sched.dow, err = parseField(fields[4], dowBounds); err != nil {
+		return nil, err
+	}
+	// 7 is an alias of sunday
+	if sched.dow&(1<<7) > 0 {
+		sched.dow = sched.dow&^(1<<7) | 1
+	}
+	sched.domStar = fields[2] == "*" || fields[2] == "?"
+	sched.dowStar = fields[4] == "*" || fields[4] == "?"
+	return
+}
+
+func parseField(field string, b bounds) (bits uint64, err error) {
+	for _, part := range strings.Split(field, ",") {
+		var start, end, step int
+		step = 1
+		rangePart := part
+		if idx := strings.Index(part, "/"); idx > -1 {
+			rangePart = part[:idx]
+			step, err = strconv.Atoi(part[idx+1:])
+			if err != nil || step < 1 {
+				return 0, fmt.Errorf("cron: invalid step in %q", part)
+			}
+		}
+		switch {
+		case rangePart == "*" || rangePart == "?":
+			start, end = b.min, b.max
+		case strings.Contains(rangePart, "-"):
+			idx := strings.Index(rangePart, "-")
+			if start, err = parseValue(rangePart[:idx], b); err != nil {
+				return
+			}
+			if end, err = parseValue(rangePart[idx+1:], b); err != nil {
+				return
+			}
+		default:
+			if start, err = parseValue(rangePart, b); err != nil {
+				return
+			}
+			end = start
+			if step > 1 {
+				end = b.max
+			}
+		}
+		if start > end {
+			return 0, fmt.Errorf("cron: invalid range %q", part)
+		}
+		for i := start; i <= end; i += step {
+			bits |= 1 << uint(i)
+		}
+	}
+	return
+}
+
+func parseValue(value string, b bounds) (int, error) {
+	if n, ok := b.names[strings.ToLower(value)]; ok {
+		return n, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("cron: invalid value %q", value)
+	}
+	if n < b.min || n > b.max {
+		return 0, fmt.Errorf("cron: value %d out of range [%d, %d]", n, b.min, b.max)
+	}
+	return n, nil
+}
+
+func (sched *Schedule) dayMatches(t time.Time) bool {
+	domMatch := sched.dom&(1<<uint(t.Day())) > 0
+	dowMatch := sched.dow&(1<<uint(t.Weekday())) > 0
+	if sched.domStar || sched.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// Next returns the first activation time strictly after t, or the zero time
+// when no activation exists in the next five years.
+func (sched *Schedule) Next(t time.Time) time.Time {
+	if sched.every > 0 {
+		return t.Add(sched.every).Truncate(time.Second)
+	}
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if sched.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !sched.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if sched.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if sched.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
@@ -0,0 +1,55 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNext(t *testing.T) {
+	var base = time.Date(2016, time.March, 14, 10, 27, 30, 0, time.UTC)
+	var cases = map[string]time.Time{
+		"* * * * *":       time.Date(2016, time.March, 14, 10, 28, 0, 0, time.UTC),
+		"*/15 * * * *":    time.Date(2016, time.March, 14, 10, 30, 0, 0, time.UTC),
+		"0 9-17 * * *":    time.Date(2016, time.March, 14, 11, 0, 0, 0, time.UTC),
+		"30 2 * * *":      time.Date(2016, time.March, 15, 2, 30, 0, 0, time.UTC),
+		"0 0 1 * *":       time.Date(2016, time.April, 1, 0, 0, 0, 0, time.UTC),
+		"0 0 * * sun":     time.Date(2016, time.March, 20, 0, 0, 0, 0, time.UTC),
+		"0 0 * * 7":       time.Date(2016, time.March, 20, 0, 0, 0, 0, time.UTC),
+		"0 0 29 feb *":    time.Date(2020, time.February, 29, 0, 0, 0, 0, time.UTC),
+		"0 0 13 * fri":    time.Date(2016, time.March, 18, 0, 0, 0, 0, time.UTC),
+		"@hourly":         time.Date(2016, time.March, 14, 11, 0, 0, 0, time.UTC),
+		"@every 90s":      time.Date(2016, time.March, 14, 10, 29, 0, 0, time.UTC),
+		"5,10 10 14 3 *":  time.Date(2017, time.March, 14, 10, 5, 0, 0, time.UTC),
+		"1-59/29 * * * *": time.Date(2016, time.March, 14, 10, 30, 0, 0, time.UTC),
+	}
+	for expr, except := range cases {
+		sched, err := Parse(expr)
+		if err != nil {
+			t.Fatalf("Parse %q: %s\n", expr, err)
+		}
+		got := sched.Next(base)
+		if !got.Equal(except) {
+			t.Fatalf("Next %q: except: %s, got: %s\n", expr, except, got)
+		}
+	}
+}
+
+func TestParseError(t *testing.T) {
+	var exprs = []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"@every 1ms",
+		"@never",
+	}
+	for _, expr := range exprs {
+		if _, err := Parse(expr); err == nil {
+			t.Fatalf("Parse %q: except error, got nil\n", expr)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package periodic
+
+import (
+	"fmt"
+	"github.com/Lupino/periodic/driver"
+	"time"
+)
+
+// listJobs returns the jobs of a func, filter by the status when it is not empty
+func (sched *Sched) listJobs(Func, status string) []driver.Job {
+	var jobs = make([]driver.Job, 0)
+	iter := sched.driver.NewIterator([]byte(Func))
+	for {
+		if !iter.Next() {
+			break
+		}
+		job := iter.Value()
+		if job.Func != Func {
+			continue
+		}
+		if status != "" && job.Status != status {
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	iter.Close()
+	return jobs
+}
+
+// deadJobs returns the dead job of func with name, all the dead jobs of func
+// when name is empty.
+func (sched *Sched) deadJobs(Func, name string) ([]driver.Job, error) {
+	if Func == "" {
+		return nil, fmt.Errorf("func is required")
+	}
+	if name == "" {
+		return sched.listJobs(Func, "dead"), nil
+	}
+	job, err := sched.driver.GetOne(Func, name)
+	if err != nil {
+		return nil, err
+	}
+	if job.ID == 0 {
+		return nil, fmt.Errorf("Job %s:%s not exists.", Func, name)
+	}
+	if !job.IsDead() {
+		return nil, fmt.Errorf("Job %s:%s is not dead.", Func, name)
+	}
+	return []driver.Job{job}, nil
+}
+
+// redriveJob move dead jobs back to ready, see deadJobs for the arguments
+func (sched *Sched) redriveJob(Func, name string) error {
+	defer sched.notifyJobTimer()
+	defer sched.jobLocker.Unlock()
+	sched.jobLocker.Lock()
+	jobs, err := sched.deadJobs(Func, name)
+	if err != nil {
+		return err
+	}
+	now := int64(time.Now().Unix())
+	for _, job := range jobs {
+		job.SetReady()
+		job.Attempts = 0
+		if job.SchedAt < now {
+			job.SchedAt = now
+		}
+		if err = sched.driver.Save(&job); err != nil {
+			return err
+		}
+		sched.pushJobPQ(job)
+	}
+	return nil
+}
+
+// purgeJob delete dead jobs, see deadJobs for the arguments
+func (sched *Sched) purgeJob(Func, name string) error {
+	defer sched.jobLocker.Unlock()
+	sched.jobLocker.Lock()
+	jobs, err := sched.deadJobs(Func, name)
+	if err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		if err = sched.driver.Delete(job.ID); err != nil {
+			return err
+		}
+		sched.decrStatJob(job)
+		sched.emit("removed", job, "")
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package periodic
+
+import (
+	"fmt"
+	"github.com/Lupino/periodic/queue"
+	"sort"
+	"sync"
+)
+
+// DispatchPolicy choose which func is served next from the first item of
+// every func that is able to dispatch a job.
+type DispatchPolicy interface {
+	// Choose returns the func to serve next
+	Choose(items map[string]*queue.Item, now int64) string
+	// Served is called when a job of func is assigned to a worker
+	Served(Func string)
+}
+
+// earliestFirst choose the func with the globally earliest item
+func earliestFirst(items map[string]*queue.Item, now int64) (lessFunc string) {
+	var lessItem *queue.Item
+	for Func, item := range items {
+		if lessItem == nil || queue.Less(item, lessItem, now) {
+			lessItem = item
+			lessFunc = Func
+		}
+	}
+	return
+}
+
+// dueFuncs returns the sorted funcs whose item is due
+func dueFuncs(items map[string]*queue.Item, now int64) []string {
+	var funcs = make([]string, 0, len(items))
+	for Func, item := range items {
+		if item.Priority <= now {
+			funcs = append(funcs, Func)
+		}
+	}
+	sort.Strings(funcs)
+	return funcs
+}
+
+type earliestPolicy struct{}
+
+func (earliestPolicy) Choose(items map[string]*queue.Item, now int64) string {
+	return earliestFirst(items, now)
+}
+
+func (earliestPolicy) Served(Func string) {}
+
+// roundRobinPolicy serve the funcs with due jobs in turn
+type roundRobinPolicy struct {
+	last   string
+	locker *sync.Mutex
+}
+
+func (p *roundRobinPolicy) Choose(items map[string]*queue.Item, now int64) string {
+	funcs := dueFuncs(items, now)
+	if len(funcs) == 0 {
+		return earliestFirst(items, now)
+	}
+	defer p.locker.Unlock()
+	p.locker.Lock()
+	for _, Func := range funcs {
+		if Func > p.last {
+			return Func
+		}
+	}
+	return funcs[0]
+}
+
+func (p *roundRobinPolicy) Served(Func string) {
+	defer p.locker.Unlock()
+	p.locker.Lock()
+	p.last = Func
+}
+
+// weightedFairPolicy serve the funcs with due jobs in proportion to their
+// weight, the func with the least virtual time is served next.
+type weightedFairPolicy struct {
+	weight func(string) int
+	vtime  map[string]float64
+	clock  float64
+	locker *sync.Mutex
+}
+
+func (p *weightedFairPolicy) virtualTime(Func string) float64 {
+	if vtime := p.vtime[Func]; vtime > p.clock {
+		return vtime
+	}
+	return p.clock
+}
+
+func (p *weightedFairPolicy) Choose(items map[string]*queue.Item, now int64) (lessFunc string) {
+	funcs := dueFuncs(items, now)
+	if len(funcs) == 0 {
+		return earliestFirst(items, now)
+	}
+	defer p.locker.Unlock()
+	p.locker.Lock()
+	var lessTime float64
+	for _, Func := range funcs {
+		vtime := p.virtualTime(Func)
+		if lessFunc == "" || vtime < lessTime ||
+			(vtime == lessTime && queue.Less(items[Func], items[lessFunc], now)) {
+			lessFunc = Func
+			lessTime = vtime
+		}
+	}
+	return
+}
+
+func (p *weightedFairPolicy) Served(Func string) {
+	defer p.locker.Unlock()
+	p.locker.Lock()
+	weight := p.weight(Func)
+	if weight < 1 {
+		weight = 1
+	}
+	p.clock = p.virtualTime(Func)
+	p.vtime[Func] = p.clock + 1/float64(weight)
+}
+
+// SetDispatchPolicy set the policy to choose the func served next:
+// earliest serve the globally earliest job (the default), roundrobin serve
+// the funcs in turn and wfq serve the funcs in proportion to their weight.
+func (sched *Sched) SetDispatchPolicy(name string) error {
+	var policy DispatchPolicy
+	switch name {
+	case "", "earliest":
+		policy = earliestPolicy{}
+	case "roundrobin":
+		policy = &roundRobinPolicy{locker: new(sync.Mutex)}
+	case "wfq":
+		policy = &weightedFairPolicy{
+			weight: sched.funcWeight,
+			vtime:  make(map[string]float64),
+			locker: new(sync.Mutex),
+		}
+	default:
+		return fmt.Errorf("unknown dispatch policy: %s", name)
+	}
+	defer sched.PQLocker.Unlock()
+	sched.PQLocker.Lock()
+	sched.policy = policy
+	return nil
+}
@@ -0,0 +1,77 @@
+package periodic
+
+import (
+	"github.com/Lupino/periodic/queue"
+	"sync"
+	"testing"
+)
+
+func dueItems(funcs ...string) map[string]*queue.Item {
+	var items = make(map[string]*queue.Item)
+	for i, Func := range funcs {
+		items[Func] = &queue.Item{Value: int64(i), Priority: int64(i)}
+	}
+	return items
+}
+
+func TestRoundRobinPolicy(t *testing.T) {
+	var policy = &roundRobinPolicy{locker: new(sync.Mutex)}
+	var items = dueItems("c", "a", "b")
+	var except = []string{"a", "b", "c", "a", "b", "c"}
+	for i, e := range except {
+		Func := policy.Choose(items, 10)
+		if Func != e {
+			t.Fatalf("Choose %d: except: %s, got: %s\n", i, e, Func)
+		}
+		policy.Served(Func)
+	}
+
+	// the func without due job is skipped
+	items["b"].Priority = 20
+	for i, e := range []string{"a", "c", "a"} {
+		Func := policy.Choose(items, 10)
+		if Func != e {
+			t.Fatalf("Choose due %d: except: %s, got: %s\n", i, e, Func)
+		}
+		policy.Served(Func)
+	}
+}
+
+func TestWeightedFairPolicy(t *testing.T) {
+	var weights = map[string]int{"a": 1, "b": 2, "c": 3}
+	var policy = &weightedFairPolicy{
+		weight: func(Func string) int { return weights[Func] },
+		vtime:  make(map[string]float64),
+		locker: new(sync.Mutex),
+	}
+	var items = dueItems("a", "b", "c")
+	var served = make(map[string]int)
+	for i := 0; i < 600; i++ {
+		Func := policy.Choose(items, 10)
+		served[Func]++
+		policy.Served(Func)
+	}
+	for Func, weight := range weights {
+		if except := weight * 100; served[Func] < except-1 || served[Func] > except+1 {
+			t.Fatalf("Served %s: except: %d, got: %d\n", Func, except, served[Func])
+		}
+	}
+}
+
+func TestWeightedFairPolicyZeroWeight(t *testing.T) {
+	var policy = &weightedFairPolicy{
+		weight: func(Func string) int { return 0 },
+		vtime:  make(map[string]float64),
+		locker: new(sync.Mutex),
+	}
+	var items = dueItems("a", "b")
+	var served = make(map[string]int)
+	for i := 0; i < 100; i++ {
+		Func := policy.Choose(items, 10)
+		served[Func]++
+		policy.Served(Func)
+	}
+	if served["a"] != 50 || served["b"] != 50 {
+		t.Fatalf("Served: except: 50 50, got: %d %d\n", served["a"], served["b"])
+	}
+}
@@ -12,6 +12,20 @@ type StoreDriver interface {
 	GetOne(string, string) (Job, error)
 	// NewIterator create a job Iterator with func or nil.
 	NewIterator([]byte) Iterator
+	// SaveResult save the result of a job, replace the old one.
+	SaveResult(Result) error
+	// GetResult get the result of a job with func and name.
+	GetResult(string, string) (Result, error)
+	// ExpireResults delete the results expired at now.
+	ExpireResults(now int64) error
+	// SetPaused persist the paused state of a func.
+	SetPaused(string, bool) error
+	// PausedFuncs get the paused funcs.
+	PausedFuncs() ([]string, error)
+	// SetFuncConfig persist the config of a func, nil to remove it.
+	SetFuncConfig(string, []byte) error
+	// FuncConfigs get the configs of the funcs.
+	FuncConfigs() (map[string][]byte, error)
 	// Close the driver
 	Close() error
 }
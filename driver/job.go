@@ -7,15 +7,24 @@ import (
 
 // Job workload.
 type Job struct {
-	ID      int64  `json:"job_id"`
-	Name    string `json:"name"`     // The job name, this is unique.
-	Func    string `json:"func"`     // The job function reffer on worker function
-	Args    string `json:"workload"` // Job args
-	Timeout int64  `json:"timeout"`  // Job processing timeout
-	SchedAt int64  `json:"sched_at"` // When to sched the job.
-	RunAt   int64  `json:"run_at"`   // The job is start at
-	Counter int64  `json:"counter"`  // The job run counter
-	Status  string `json:"status"`
+	ID          int64             `json:"job_id"`
+	Name        string            `json:"name"`     // The job name, this is unique.
+	Func        string            `json:"func"`     // The job function reffer on worker function
+	Args        string            `json:"workload"` // Job args
+	Timeout     int64             `json:"timeout"`  // Job processing timeout
+	SchedAt     int64             `json:"sched_at"` // When to sched the job.
+	RunAt       int64             `json:"run_at"`   // The job is start at
+	Counter     int64             `json:"counter"`  // The job run counter
+	Status      string            `json:"status"`
+	Cron        string            `json:"cron"`        // The cron expression of a recurring job
+	Retry       *RetryPolicy      `json:"retry"`       // The retry policy on fail, nil use the func default
+	Attempts    int64             `json:"attempts"`    // The failed attempts of the job
+	Priority    int64             `json:"priority"`    // The due jobs with higher priority are served first
+	LeaseUntil  int64             `json:"lease_until"` // The processing job is not timeout before, extended by the worker
+	Progress    int64             `json:"progress"`    // The percentage of the processing job reported by the worker
+	ProgressMsg string            `json:"progress_msg"`
+	Selector    map[string]string `json:"selector"`  // The labels the worker must have to run the job
+	Broadcast   bool              `json:"broadcast"` // The job runs once on every worker of the func
 }
 
 // IsReady check job status ready
@@ -28,6 +37,11 @@ func (job Job) IsProc() bool {
 	return job.Status == "processing"
 }
 
+// IsDead check job status dead, a dead job is no longer scheduled
+func (job Job) IsDead() bool {
+	return job.Status == "dead"
+}
+
 // SetReady set job status ready
 func (job *Job) SetReady() {
 	job.Status = "ready"
@@ -38,6 +52,34 @@ func (job *Job) SetProc() {
 	job.Status = "processing"
 }
 
+// SetDead set job status dead
+func (job *Job) SetDead() {
+	job.Status = "dead"
+}
+
+// MatchLabels check the labels satisfy the job selector
+func (job Job) MatchLabels(labels map[string]string) bool {
+	for k, v := range job.Selector {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Deadline returns when the processing job is timeout
+func (job Job) Deadline() int64 {
+	runAt := job.RunAt
+	if runAt == 0 {
+		runAt = job.SchedAt
+	}
+	deadline := runAt + job.Timeout
+	if job.LeaseUntil > deadline {
+		return job.LeaseUntil
+	}
+	return deadline
+}
+
 // NewJob create a job from json bytes
 func NewJob(payload []byte) (job Job, err error) {
 	err = json.Unmarshal(payload, &job)
@@ -50,7 +92,9 @@ func (job Job) Bytes() (data []byte) {
 	return
 }
 
-// Decode create a job from bytes
+// Decode create a job from bytes.
+// The optional settings that go-periodic types.Job can not carry are read
+// from a json object appended after the job bytes.
 func Decode(payload []byte) (job Job, err error) {
 	j, err := types.NewJob(payload)
 	if err != nil {
@@ -61,6 +105,23 @@ func Decode(payload []byte) (job Job, err error) {
 	job.Args = j.Args
 	job.SchedAt = j.SchedAt
 	job.Counter = j.Counter
+	if size := len(j.Bytes()); size < len(payload) {
+		err = decodeOptions(&job, payload[size:])
+	}
+	return
+}
+
+func decodeOptions(job *Job, data []byte) (err error) {
+	var opts Job
+	if err = json.Unmarshal(data, &opts); err != nil {
+		return
+	}
+	job.Timeout = opts.Timeout
+	job.Cron = opts.Cron
+	job.Retry = opts.Retry
+	job.Priority = opts.Priority
+	job.Selector = opts.Selector
+	job.Broadcast = opts.Broadcast
 	return
 }
 
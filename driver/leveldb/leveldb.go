@@ -11,6 +11,7 @@ import (
 	"os"
 	"strconv"
 	"sync"
+	"time"
 )
 
 // PREJOB prefix job key
@@ -22,6 +23,15 @@ const PREFUNC = "func:"
 // PRESEQUENCE prefix sequence key
 const PRESEQUENCE = "sequence:"
 
+// PRERESULT prefix result key
+const PRERESULT = "result:"
+
+// PREPAUSED prefix paused func key
+const PREPAUSED = "paused:"
+
+// PRECONFIG prefix func config key
+const PRECONFIG = "config:"
+
 // Driver define leveldb store driver
 type Driver struct {
 	db       *leveldb.DB
@@ -176,6 +186,94 @@ func (l Driver) NewIterator(Func []byte) driver.Iterator {
 	}
 }
 
+// SaveResult save the result of a job, replace the old one.
+func (l Driver) SaveResult(r driver.Result) error {
+	defer l.RWLocker.Unlock()
+	l.RWLocker.Lock()
+	return l.db.Put([]byte(PRERESULT+r.Func+":"+r.Name), r.Bytes(), nil)
+}
+
+// GetResult get the result of a job with func and name.
+func (l Driver) GetResult(Func, name string) (r driver.Result, err error) {
+	defer l.RWLocker.Unlock()
+	l.RWLocker.Lock()
+	var data []byte
+	data, err = l.db.Get([]byte(PRERESULT+Func+":"+name), nil)
+	if err != nil {
+		return
+	}
+	r, err = driver.NewResult(data)
+	if err == nil && r.IsExpired(time.Now().Unix()) {
+		err = fmt.Errorf("Result %s:%s not exists.", Func, name)
+	}
+	return
+}
+
+// ExpireResults delete the results expired at now.
+func (l Driver) ExpireResults(now int64) error {
+	defer l.RWLocker.Unlock()
+	l.RWLocker.Lock()
+	batch := new(leveldb.Batch)
+	iter := l.db.NewIterator(util.BytesPrefix([]byte(PRERESULT)), nil)
+	for iter.Next() {
+		r, err := driver.NewResult(iter.Value())
+		if err != nil || r.IsExpired(now) {
+			batch.Delete(append([]byte(nil), iter.Key()...))
+		}
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return err
+	}
+	return l.db.Write(batch, nil)
+}
+
+// SetPaused persist the paused state of a func.
+func (l Driver) SetPaused(Func string, paused bool) error {
+	defer l.RWLocker.Unlock()
+	l.RWLocker.Lock()
+	if paused {
+		return l.db.Put([]byte(PREPAUSED+Func), []byte("1"), nil)
+	}
+	return l.db.Delete([]byte(PREPAUSED+Func), nil)
+}
+
+// PausedFuncs get the paused funcs.
+func (l Driver) PausedFuncs() ([]string, error) {
+	defer l.RWLocker.Unlock()
+	l.RWLocker.Lock()
+	var funcs = make([]string, 0)
+	iter := l.db.NewIterator(util.BytesPrefix([]byte(PREPAUSED)), nil)
+	for iter.Next() {
+		funcs = append(funcs, string(iter.Key()[len(PREPAUSED):]))
+	}
+	iter.Release()
+	return funcs, iter.Error()
+}
+
+// SetFuncConfig persist the config of a func, nil to remove it.
+func (l Driver) SetFuncConfig(Func string, config []byte) error {
+	defer l.RWLocker.Unlock()
+	l.RWLocker.Lock()
+	if len(config) == 0 {
+		return l.db.Delete([]byte(PRECONFIG+Func), nil)
+	}
+	return l.db.Put([]byte(PRECONFIG+Func), config, nil)
+}
+
+// FuncConfigs get the configs of the funcs.
+func (l Driver) FuncConfigs() (map[string][]byte, error) {
+	defer l.RWLocker.Unlock()
+	l.RWLocker.Lock()
+	var configs = make(map[string][]byte)
+	iter := l.db.NewIterator(util.BytesPrefix([]byte(PRECONFIG)), nil)
+	for iter.Next() {
+		configs[string(iter.Key()[len(PRECONFIG):])] = append([]byte(nil), iter.Value()...)
+	}
+	iter.Release()
+	return configs, iter.Error()
+}
+
 // Close the driver
 func (l Driver) Close() error {
 	err := l.db.Close()
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strings"
 	"sync"
+	"time"
 )
 
 // MemStoreDriver defined a memory store driver
@@ -11,6 +12,9 @@ type MemStoreDriver struct {
 	data      map[int64]*Job
 	nameIndex map[string]int64
 	lastID    int64
+	results   map[string]Result
+	paused    map[string]bool
+	configs   map[string][]byte
 	locker    *sync.Mutex
 }
 
@@ -20,6 +24,9 @@ func NewMemStroeDriver() *MemStoreDriver {
 	mem.locker = new(sync.Mutex)
 	mem.nameIndex = make(map[string]int64)
 	mem.data = make(map[int64]*Job)
+	mem.results = make(map[string]Result)
+	mem.paused = make(map[string]bool)
+	mem.configs = make(map[string][]byte)
 	mem.lastID = 0
 	return mem
 }
@@ -105,6 +112,83 @@ func (m *MemStoreDriver) NewIterator(Func []byte) Iterator {
 	}
 }
 
+// SaveResult save the result of a job, replace the old one.
+func (m *MemStoreDriver) SaveResult(r Result) error {
+	defer m.locker.Unlock()
+	m.locker.Lock()
+	m.results[r.Func+":"+r.Name] = r
+	return nil
+}
+
+// GetResult get the result of a job with func and name.
+func (m *MemStoreDriver) GetResult(Func, name string) (r Result, err error) {
+	defer m.locker.Unlock()
+	m.locker.Lock()
+	r, ok := m.results[Func+":"+name]
+	if !ok || r.IsExpired(time.Now().Unix()) {
+		err = fmt.Errorf("Result %s:%s not exists.", Func, name)
+	}
+	return
+}
+
+// SetPaused persist the paused state of a func.
+func (m *MemStoreDriver) SetPaused(Func string, paused bool) error {
+	defer m.locker.Unlock()
+	m.locker.Lock()
+	if paused {
+		m.paused[Func] = true
+	} else {
+		delete(m.paused, Func)
+	}
+	return nil
+}
+
+// PausedFuncs get the paused funcs.
+func (m *MemStoreDriver) PausedFuncs() ([]string, error) {
+	defer m.locker.Unlock()
+	m.locker.Lock()
+	var funcs = make([]string, 0, len(m.paused))
+	for Func := range m.paused {
+		funcs = append(funcs, Func)
+	}
+	return funcs, nil
+}
+
+// SetFuncConfig persist the config of a func, nil to remove it.
+func (m *MemStoreDriver) SetFuncConfig(Func string, config []byte) error {
+	defer m.locker.Unlock()
+	m.locker.Lock()
+	if len(config) == 0 {
+		delete(m.configs, Func)
+	} else {
+		m.configs[Func] = append([]byte(nil), config...)
+	}
+	return nil
+}
+
+// FuncConfigs get the configs of the funcs.
+func (m *MemStoreDriver) FuncConfigs() (map[string][]byte, error) {
+	defer m.locker.Unlock()
+	m.locker.Lock()
+	var configs = make(map[string][]byte, len(m.configs))
+	for Func, config := range m.configs {
+		configs[Func] = config
+	}
+	return configs, nil
+}
+
+// ExpireResults delete the results expired at now.
+func (m *MemStoreDriver) ExpireResults(now int64) error {
+	defer m.locker.Unlock()
+	m.locker.Lock()
+	for key, r := range m.results {
+		if r.IsExpired(now) {
+			delete(m.results, key)
+		}
+	}
+	return nil
+}
+
 // Close the driver
 func (m *MemStoreDriver) Close() error {
 	return nil
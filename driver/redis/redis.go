@@ -10,11 +10,21 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
 // PREFIX the redis key prefix
 const PREFIX = "periodic:job:"
 
+// PRERESULT the redis result key prefix
+const PRERESULT = "periodic:result:"
+
+// PAUSED the redis set key of the paused funcs
+const PAUSED = "periodic:paused"
+
+// CONFIGS the redis hash key of the func configs
+const CONFIGS = "periodic:configs"
+
 // Driver define a redis store driver
 type Driver struct {
 	pool     *redis.Pool
@@ -156,6 +166,89 @@ func (r Driver) NewIterator(Func []byte) driver.Iterator {
 	}
 }
 
+// SaveResult save the result of a job, replace the old one.
+// The result key is expired by redis.
+func (r Driver) SaveResult(result driver.Result) (err error) {
+	var conn = r.pool.Get()
+	defer conn.Close()
+	var key = PRERESULT + result.Func + ":" + result.Name
+	if result.ExpireAt > 0 {
+		ttl := result.ExpireAt - time.Now().Unix()
+		if ttl < 1 {
+			ttl = 1
+		}
+		_, err = conn.Do("SET", key, result.Bytes(), "EX", ttl)
+	} else {
+		_, err = conn.Do("SET", key, result.Bytes())
+	}
+	return
+}
+
+// GetResult get the result of a job with func and name.
+func (r Driver) GetResult(Func, name string) (result driver.Result, err error) {
+	var conn = r.pool.Get()
+	defer conn.Close()
+	var data []byte
+	data, err = redis.Bytes(conn.Do("GET", PRERESULT+Func+":"+name))
+	if err != nil {
+		return
+	}
+	result, err = driver.NewResult(data)
+	return
+}
+
+// ExpireResults delete the results expired at now.
+// Nothing to do, redis expire the results itself.
+func (r Driver) ExpireResults(now int64) error {
+	return nil
+}
+
+// SetPaused persist the paused state of a func.
+func (r Driver) SetPaused(Func string, paused bool) (err error) {
+	var conn = r.pool.Get()
+	defer conn.Close()
+	if paused {
+		_, err = conn.Do("SADD", PAUSED, Func)
+	} else {
+		_, err = conn.Do("SREM", PAUSED, Func)
+	}
+	return
+}
+
+// PausedFuncs get the paused funcs.
+func (r Driver) PausedFuncs() ([]string, error) {
+	var conn = r.pool.Get()
+	defer conn.Close()
+	return redis.Strings(conn.Do("SMEMBERS", PAUSED))
+}
+
+// SetFuncConfig persist the config of a func, nil to remove it.
+func (r Driver) SetFuncConfig(Func string, config []byte) (err error) {
+	var conn = r.pool.Get()
+	defer conn.Close()
+	if len(config) == 0 {
+		_, err = conn.Do("HDEL", CONFIGS, Func)
+	} else {
+		_, err = conn.Do("HSET", CONFIGS, Func, config)
+	}
+	return
+}
+
+// FuncConfigs get the configs of the funcs.
+func (r Driver) FuncConfigs() (map[string][]byte, error) {
+	var conn = r.pool.Get()
+	defer conn.Close()
+	values, err := redis.StringMap(conn.Do("HGETALL", CONFIGS))
+	if err != nil {
+		return nil, err
+	}
+	var configs = make(map[string][]byte, len(values))
+	for Func, config := range values {
+		configs[Func] = []byte(config)
+	}
+	return configs, nil
+}
+
 // Close the redis driver
 func (r Driver) Close() error {
 	return nil
@@ -0,0 +1,32 @@
+package driver
+
+import (
+	"encoding/json"
+)
+
+// Result defined the outcome of a finished job.
+type Result struct {
+	Func     string `json:"func"`
+	Name     string `json:"name"`
+	Status   string `json:"status"`    // The final status of the job
+	Data     string `json:"result"`    // The result payload reported by the worker
+	DoneAt   int64  `json:"done_at"`   // When the job is finished
+	ExpireAt int64  `json:"expire_at"` // When the result is expired, 0 is never
+}
+
+// IsExpired check the result is expired at now
+func (r Result) IsExpired(now int64) bool {
+	return r.ExpireAt > 0 && r.ExpireAt <= now
+}
+
+// NewResult create a result from json bytes
+func NewResult(payload []byte) (r Result, err error) {
+	err = json.Unmarshal(payload, &r)
+	return
+}
+
+// Bytes encode result to json bytes
+func (r Result) Bytes() (data []byte) {
+	data, _ = json.Marshal(r)
+	return
+}
@@ -0,0 +1,42 @@
+package driver
+
+import (
+	"math"
+	"math/rand"
+)
+
+// RetryPolicy defined how a failed job is retried.
+type RetryPolicy struct {
+	MaxAttempts int64   `json:"max_attempts"` // Stop retrying after attempts, 0 is unlimited
+	BaseDelay   int64   `json:"base_delay"`   // Seconds to wait before the first retry
+	Multiplier  float64 `json:"multiplier"`   // The delay grows by multiplier on every attempt
+	MaxDelay    int64   `json:"max_delay"`    // The upper bound of the delay, 0 is unbounded
+	Jitter      float64 `json:"jitter"`       // Randomize the delay by +/- jitter ratio
+}
+
+// Exhausted check the job attempts reach the max attempts
+func (p RetryPolicy) Exhausted(attempts int64) bool {
+	return p.MaxAttempts > 0 && attempts >= p.MaxAttempts
+}
+
+// Delay returns the seconds to wait before retry the job on attempts
+func (p RetryPolicy) Delay(attempts int64) int64 {
+	multiplier := p.Multiplier
+	if multiplier <= 0 {
+		multiplier = 1
+	}
+	if attempts < 1 {
+		attempts = 1
+	}
+	delay := float64(p.BaseDelay) * math.Pow(multiplier, float64(attempts-1))
+	if p.MaxDelay > 0 && delay > float64(p.MaxDelay) {
+		delay = float64(p.MaxDelay)
+	}
+	if p.Jitter > 0 {
+		delay = delay + delay*p.Jitter*(2*rand.Float64()-1)
+	}
+	if delay < 0 {
+		return 0
+	}
+	return int64(delay + 0.5)
+}
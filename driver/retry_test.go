@@ -0,0 +1,58 @@
+package driver
+
+import (
+	"testing"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+	var tests = []struct {
+		name     string
+		policy   RetryPolicy
+		attempts int64
+		delay    int64
+	}{
+		{"fixed", RetryPolicy{BaseDelay: 5}, 1, 5},
+		{"fixed", RetryPolicy{BaseDelay: 5}, 4, 5},
+		{"fixed zero multiplier", RetryPolicy{BaseDelay: 5, Multiplier: 0}, 3, 5},
+		{"exponential", RetryPolicy{BaseDelay: 1, Multiplier: 2}, 1, 1},
+		{"exponential", RetryPolicy{BaseDelay: 1, Multiplier: 2}, 2, 2},
+		{"exponential", RetryPolicy{BaseDelay: 1, Multiplier: 2}, 5, 16},
+		{"exponential first attempt", RetryPolicy{BaseDelay: 3, Multiplier: 2}, 0, 3},
+		{"cap", RetryPolicy{BaseDelay: 1, Multiplier: 2, MaxDelay: 10}, 4, 8},
+		{"cap", RetryPolicy{BaseDelay: 1, Multiplier: 2, MaxDelay: 10}, 5, 10},
+		{"cap", RetryPolicy{BaseDelay: 1, Multiplier: 2, MaxDelay: 10}, 20, 10},
+	}
+	for _, test := range tests {
+		if delay := test.policy.Delay(test.attempts); delay != test.delay {
+			t.Fatalf("Delay %s %d: except: %d, got: %d\n", test.name, test.attempts, test.delay, delay)
+		}
+	}
+}
+
+func TestRetryPolicyDelayJitter(t *testing.T) {
+	var policy = RetryPolicy{BaseDelay: 100, Jitter: 0.2}
+	for i := 0; i < 100; i++ {
+		if delay := policy.Delay(1); delay < 80 || delay > 120 {
+			t.Fatalf("Delay jitter: except: 80-120, got: %d\n", delay)
+		}
+	}
+}
+
+func TestRetryPolicyExhausted(t *testing.T) {
+	var tests = []struct {
+		policy    RetryPolicy
+		attempts  int64
+		exhausted bool
+	}{
+		{RetryPolicy{MaxAttempts: 3}, 0, false},
+		{RetryPolicy{MaxAttempts: 3}, 2, false},
+		{RetryPolicy{MaxAttempts: 3}, 3, true},
+		{RetryPolicy{MaxAttempts: 3}, 4, true},
+		{RetryPolicy{MaxAttempts: 0}, 100, false},
+	}
+	for _, test := range tests {
+		if exhausted := test.policy.Exhausted(test.attempts); exhausted != test.exhausted {
+			t.Fatalf("Exhausted %d of %d: except: %v, got: %v\n", test.attempts, test.policy.MaxAttempts, test.exhausted, exhausted)
+		}
+	}
+}
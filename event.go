@@ -0,0 +1,82 @@
+package periodic
+
+import (
+	"encoding/json"
+	"github.com/Lupino/periodic/driver"
+	"time"
+)
+
+// Event defined a job lifecycle event
+type Event struct {
+	Type     string `json:"type"` // submitted, assigned, progress, done, failed, rescheduled, timeout, dead, removed, canceled, cancel_acked or broadcast_done
+	Func     string `json:"func"`
+	Name     string `json:"name"`
+	JobID    int64  `json:"job_id"`
+	Worker   string `json:"worker,omitempty"` // The worker the job assigned to
+	Progress int64  `json:"progress,omitempty"`
+	Message  string `json:"message,omitempty"` // The progress message
+	At       int64  `json:"at"`
+}
+
+// Bytes encode event to json bytes
+func (ev Event) Bytes() (data []byte) {
+	data, _ = json.Marshal(ev)
+	return
+}
+
+type subscriber struct {
+	Func string
+	ch   chan Event
+}
+
+// subscribe the events of func, all the funcs when Func is empty
+func (sched *Sched) subscribe(Func string) *subscriber {
+	defer sched.subLocker.Unlock()
+	sched.subLocker.Lock()
+	sub := &subscriber{
+		Func: Func,
+		ch:   make(chan Event, 100),
+	}
+	sched.subscribers[sub] = true
+	return sub
+}
+
+func (sched *Sched) unsubscribe(sub *subscriber) {
+	defer sched.subLocker.Unlock()
+	sched.subLocker.Lock()
+	if _, ok := sched.subscribers[sub]; ok {
+		delete(sched.subscribers, sub)
+		close(sub.ch)
+	}
+}
+
+// emit an event of job to the subscribers, the event is dropped for the
+// subscriber not keep up with.
+func (sched *Sched) emit(typ string, job driver.Job, worker string) {
+	defer sched.subLocker.Unlock()
+	sched.subLocker.Lock()
+	if len(sched.subscribers) == 0 {
+		return
+	}
+	ev := Event{
+		Type:   typ,
+		Func:   job.Func,
+		Name:   job.Name,
+		JobID:  job.ID,
+		Worker: worker,
+		At:     time.Now().Unix(),
+	}
+	if typ == "progress" {
+		ev.Progress = job.Progress
+		ev.Message = job.ProgressMsg
+	}
+	for sub := range sched.subscribers {
+		if sub.Func != "" && sub.Func != job.Func {
+			continue
+		}
+		select {
+		case sub.ch <- ev:
+		default:
+		}
+	}
+}
@@ -4,21 +4,24 @@ import (
 	"bytes"
 	"container/list"
 	"fmt"
+	"github.com/Lupino/periodic/driver"
 	"sync"
 )
 
 type grabItem struct {
 	w     *worker
 	msgID []byte
+	slots int // The jobs the worker grabs with the msgID
 }
 
-func (item grabItem) has(Func string) bool {
-	for _, F := range item.w.funcs {
-		if F == Func {
-			return true
-		}
+func (item grabItem) has(job driver.Job) bool {
+	if item.w.isDraining() {
+		return false
 	}
-	return false
+	if !item.w.canDo(job.Func) {
+		return false
+	}
+	return item.w.matchLabels(job)
 }
 
 func (item grabItem) equal(item1 grabItem) bool {
@@ -39,16 +42,17 @@ func (g *grabQueue) push(item grabItem) {
 	g.list.PushBack(item)
 }
 
-func (g *grabQueue) get(Func string) (item grabItem, err error) {
+// get a grab item can run the job, the worker must satisfy the job selector
+func (g *grabQueue) get(job driver.Job) (item grabItem, err error) {
 	defer g.locker.Unlock()
 	g.locker.Lock()
 	for e := g.list.Front(); e != nil; e = e.Next() {
 		item = e.Value.(grabItem)
-		if item.has(Func) {
+		if item.has(job) {
 			return
 		}
 	}
-	err = fmt.Errorf("func name: %s not found", Func)
+	err = fmt.Errorf("func name: %s not found", job.Func)
 	return
 }
 
@@ -63,10 +67,72 @@ func (g *grabQueue) remove(item grabItem) {
 	}
 }
 
-func (g *grabQueue) removeWorker(w *worker) {
+// getWorker get a grab item of the worker
+func (g *grabQueue) getWorker(w *worker) (item grabItem, ok bool) {
+	defer g.locker.Unlock()
+	g.locker.Lock()
+	for e := g.list.Front(); e != nil; e = e.Next() {
+		item = e.Value.(grabItem)
+		if item.w == w {
+			return item, true
+		}
+	}
+	return grabItem{}, false
+}
+
+// take a slot of the item, the item is removed when no slot left
+func (g *grabQueue) take(item grabItem) {
 	defer g.locker.Unlock()
 	g.locker.Lock()
 	for e := g.list.Front(); e != nil; e = e.Next() {
+		item1 := e.Value.(grabItem)
+		if item.equal(item1) {
+			item1.slots--
+			if item1.slots > 0 {
+				e.Value = item1
+			} else {
+				g.list.Remove(e)
+			}
+			return
+		}
+	}
+}
+
+// slots returns the jobs the worker is waiting for
+func (g *grabQueue) slots(w *worker) (slots int) {
+	defer g.locker.Unlock()
+	g.locker.Lock()
+	for e := g.list.Front(); e != nil; e = e.Next() {
+		item := e.Value.(grabItem)
+		if item.w == w {
+			slots = slots + item.slots
+		}
+	}
+	return
+}
+
+func (g *grabQueue) removeWorker(w *worker) {
+	defer g.locker.Unlock()
+	g.locker.Lock()
+	g.removeItems(w)
+}
+
+// drainWorker set the worker draining and remove its grab items at once, so a
+// grab waiting before the drain get no job.
+func (g *grabQueue) drainWorker(w *worker) {
+	defer g.locker.Unlock()
+	g.locker.Lock()
+	w.locker.Lock()
+	w.draining = true
+	w.locker.Unlock()
+	g.removeItems(w)
+}
+
+// removeItems remove the grab items of the worker, the locker must be held.
+func (g *grabQueue) removeItems(w *worker) {
+	var next *list.Element
+	for e := g.list.Front(); e != nil; e = next {
+		next = e.Next()
 		item := e.Value.(grabItem)
 		if item.w == w {
 			g.list.Remove(e)
@@ -2,143 +2,435 @@ package periodic
 
 import (
 	"bufio"
-	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/Lupino/go-periodic/protocol"
 	"github.com/Lupino/periodic/driver"
+	"log"
 	"net"
 	"net/http"
-	"strconv"
+	"net/url"
 	"strings"
+	"sync"
+	"time"
 )
 
-var (
-	bufSize = 1024
-)
+var errHTTPServerClosed = errors.New("http server closed")
 
-type httpClient struct {
-	sched *Sched
-	conn  net.Conn
+// httpServer serve the REST api of a listener, the connections are accepted
+// by the listener and handed over, so the http api can share the entry point
+// with the binary protocol.
+type httpServer struct {
+	sched    *Sched
+	listener *listener
+	server   *http.Server
+	conns    chan net.Conn
+	closed   chan struct{}
+	alive    bool
+	locker   *sync.Mutex
 }
 
-func newHTTPClient(sched *Sched, conn net.Conn) (c *httpClient) {
-	c = new(httpClient)
-	c.conn = conn
-	c.sched = sched
+func newHTTPServer(sched *Sched, l *listener) (hs *httpServer) {
+	hs = new(httpServer)
+	hs.sched = sched
+	hs.listener = l
+	hs.conns = make(chan net.Conn)
+	hs.closed = make(chan struct{})
+	hs.alive = true
+	hs.locker = new(sync.Mutex)
+	hs.server = &http.Server{
+		Handler:     hs,
+		ReadTimeout: l.timeout,
+		IdleTimeout: l.timeout,
+	}
 	return
 }
 
-func (c *httpClient) handle(header []byte) {
-	defer c.conn.Close()
-	writer := bytes.NewBuffer(header)
-	for {
-		buf := make([]byte, bufSize)
-		n, err := c.conn.Read(buf)
-		if err != nil {
-			c.sendErrResponse(err)
-			return
-		}
-		writer.Write(buf)
-		if n < bufSize {
-			break
-		}
+func (hs *httpServer) serve() {
+	if err := hs.server.Serve(hs); err != nil && err != errHTTPServerClosed && err != http.ErrServerClosed {
+		log.Printf("HTTP server error: %v\n", err)
 	}
-	req, _ := http.ReadRequest(bufio.NewReader(writer))
-
-	url := req.URL.String()
-	funcName := url[1:]
+}
 
-	switch req.Method {
-	case "GET":
-		c.handleStatus(funcName)
-		break
-	case "POST":
-		act := req.FormValue("act")
-		if strings.ToLower(act) == "remove" {
-			c.handleRemoveJob(req)
-		} else {
-			c.handleSubmitJob(req)
-		}
+// serveConn hand over a connection to the http server
+func (hs *httpServer) serveConn(conn net.Conn) {
+	// the http server keeps its own deadlines on the keep-alive connection
+	conn.SetDeadline(time.Time{})
+	select {
+	case hs.conns <- conn:
 		break
-	case "DELETE":
-		c.handleDropFunc(funcName)
-		break
-	default:
-		c.sendResponse("400 Bad Request", nil)
+	case <-hs.closed:
+		conn.Close()
 		break
 	}
 }
 
-func (c *httpClient) sendResponse(status string, body []byte) {
-	buf := bytes.NewBuffer(nil)
-	buf.WriteString("HTTP/1.1 " + status + "\r\n")
-	buf.WriteString("Content-Type: application/json; charset=utf-8\r\n")
-	buf.WriteString("Server: periodic/" + Version + "\r\n")
-	length := len(body)
-	if length > 0 {
-		buf.WriteString("Content-Length: " + strconv.Itoa(length) + "\r\n")
-		buf.WriteString("\r\n")
-		buf.Write(body)
+// Accept implements net.Listener
+func (hs *httpServer) Accept() (net.Conn, error) {
+	select {
+	case conn := <-hs.conns:
+		return conn, nil
+	case <-hs.closed:
+		return nil, errHTTPServerClosed
+	}
+}
+
+// Close implements net.Listener
+func (hs *httpServer) Close() error {
+	defer hs.locker.Unlock()
+	hs.locker.Lock()
+	if hs.alive {
+		hs.alive = false
+		close(hs.closed)
 	}
-	c.conn.Write(buf.Bytes())
+	return nil
+}
+
+// Addr implements net.Listener
+func (hs *httpServer) Addr() net.Addr {
+	return hs.listener.listen.Addr()
+}
+
+// shutdown close the http server and all its connections
+func (hs *httpServer) shutdown() {
+	hs.server.Close()
+	hs.Close()
+}
+
+// bufferedConn is a connection read through the reader peeked its first bytes
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
 }
 
-func (c *httpClient) sendErrResponse(e error) {
-	c.sendResponse("400 Bad Request",
-		[]byte("{\"err\": \""+e.Error()+"\"}"))
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
 }
 
-func (c *httpClient) handleSubmitJob(req *http.Request) {
-	var job driver.Job
-	var e error
-	var sched = c.sched
-	defer sched.jobLocker.Unlock()
-	sched.jobLocker.Lock()
-	url := req.URL.String()
-	funcName := url[1:]
-	if funcName == "" {
-		funcName = req.FormValue("func")
+// isHTTPRequest check the first bytes of a connection is a http method
+func isHTTPRequest(head []byte) bool {
+	for _, method := range []string{"GET ", "POST", "PUT ", "DELE", "HEAD"} {
+		if string(head) == method {
+			return true
+		}
 	}
-	job.Name = req.FormValue("name")
-	job.Func = funcName
-	job.Args = req.FormValue("args")
-	job.Timeout, _ = strconv.ParseInt(req.FormValue("timeout"), 10, 64)
-	job.SchedAt, _ = strconv.ParseInt(req.FormValue("sched_at"), 10, 64)
+	return false
+}
+
+// httpRoute is a matched request, command and Func are used to authorize
+type httpRoute struct {
+	command string
+	Func    string
+	handle  http.HandlerFunc
+}
+
+// httpError is a route error with its status code
+type httpError struct {
+	status int
+	err    error
+}
+
+func (e *httpError) Error() string {
+	return e.err.Error()
+}
+
+func newHTTPError(status int, format string, a ...interface{}) *httpError {
+	return &httpError{status, fmt.Errorf(format, a...)}
+}
+
+func (hs *httpServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Server", "periodic/"+Version)
 
-	if job.Name == "" || job.Func == "" {
-		c.sendErrResponse(errors.New("job name or func is required"))
+	// Authorization: Bearer <token>
+	token := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+	identity, ok := hs.listener.authenticate(hs.sched, token)
+	if !ok {
+		log.Printf("Unauthorized http request from %s\n", req.RemoteAddr)
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		sendError(w, http.StatusUnauthorized, errors.New("unauthorized"))
 		return
 	}
 
-	isNew := true
-	changed := false
-	job.SetReady()
-	oldJob, e := sched.driver.GetOne(job.Func, job.Name)
-	if e == nil && oldJob.ID > 0 {
-		job.ID = oldJob.ID
-		if job.IsProc() {
-			sched.decrStatProc(oldJob)
-			sched.removeRevertPQ(job)
-			changed = true
+	route, e := hs.route(req)
+	if e != nil {
+		if e.status == http.StatusMethodNotAllowed {
+			w.Header().Set("Allow", e.Error())
+			e.err = fmt.Errorf("Method %s not allowed.", req.Method)
 		}
-		isNew = false
+		sendError(w, e.status, e)
+		return
 	}
-	e = sched.driver.Save(&job)
-	if e != nil {
-		c.sendErrResponse(e)
+	if err := hs.sched.authorize(identity, "client", route.command, route.Func); err != nil {
+		sendError(w, http.StatusForbidden, err)
 		return
 	}
+	route.handle(w, req)
+}
 
-	if isNew {
-		sched.incrStatJob(job)
+// splitPath returns the unescaped segments of the request path
+func splitPath(u *url.URL) ([]string, error) {
+	var parts = make([]string, 0)
+	for _, part := range strings.Split(u.EscapedPath(), "/") {
+		if part == "" {
+			continue
+		}
+		part, err := url.PathUnescape(part)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, part)
 	}
-	if isNew || changed {
-		sched.pushJobPQ(job)
+	return parts, nil
+}
+
+// route match the request to the api, see the HTTP API in README
+func (hs *httpServer) route(req *http.Request) (route httpRoute, e *httpError) {
+	parts, err := splitPath(req.URL)
+	if err != nil {
+		return route, &httpError{http.StatusBadRequest, err}
+	}
+	if len(parts) == 0 {
+		return route, newHTTPError(http.StatusNotFound, "Not found.")
+	}
+	sched := hs.sched
+	var allow string
+	switch {
+	case parts[0] == "status" && len(parts) <= 2:
+		allow = "GET"
+		if len(parts) == 2 {
+			route.Func = parts[1]
+		}
+		route.command = "STATUS"
+		route.handle = hs.handleStatus(route.Func)
+		break
+	case parts[0] == "jobs" && len(parts) <= 2:
+		allow = "POST"
+		if len(parts) == 2 {
+			allow = "GET, POST"
+			route.Func = parts[1]
+			route.command = "LISTJOB"
+			route.handle = hs.handleListJob(route.Func)
+		}
+		if req.Method == "POST" {
+			var job driver.Job
+			var wait int64
+			if job, wait, e = decodeHTTPJob(req, route.Func); e != nil {
+				return
+			}
+			route.Func = job.Func
+			route.command = "SUBMITJOB"
+			route.handle = hs.handleSubmitJob(job)
+			if wait > 0 {
+				route.command = "SUBMITWAIT"
+				route.handle = hs.handleSubmitWait(job, time.Duration(wait)*time.Second)
+			}
+		}
+		break
+	case parts[0] == "jobs" && len(parts) == 3:
+		allow = "GET, DELETE"
+		route.Func = parts[1]
+		route.command = "LISTJOB"
+		route.handle = hs.handleGetJob(parts[1], parts[2])
+		if req.Method == "DELETE" {
+			route.command = "REMOVEJOB"
+			route.handle = hs.handleRemoveJob(parts[1], parts[2])
+		}
+		break
+	case parts[0] == "jobs" && len(parts) == 4:
+		allow = "POST"
+		route.Func = parts[1]
+		switch parts[3] {
+		case "result":
+			allow = "GET"
+			route.command = "GETRESULT"
+			route.handle = hs.handleGetResult(parts[1], parts[2])
+			break
+		case "redrive":
+			route.command = "REDRIVEJOB"
+			route.handle = hs.handleJobAction(parts[1], parts[2], sched.redriveJob)
+			break
+		case "purge":
+			route.command = "PURGEJOB"
+			route.handle = hs.handleJobAction(parts[1], parts[2], sched.purgeJob)
+			break
+		case "cancel":
+			route.command = "CANCELJOB"
+			route.handle = hs.handleJobAction(parts[1], parts[2], sched.cancelJob)
+			break
+		default:
+			return route, newHTTPError(http.StatusNotFound, "Not found.")
+		}
+		break
+	case parts[0] == "funcs" && len(parts) == 2:
+		allow = "DELETE"
+		route.Func = parts[1]
+		route.command = "DROPFUNC"
+		route.handle = hs.handleDropFunc(parts[1])
+		break
+	case parts[0] == "funcs" && len(parts) == 3:
+		allow = "POST"
+		route.Func = parts[1]
+		switch parts[2] {
+		case "config":
+			allow = "GET, PUT"
+			route.command = "CONFIGGET"
+			route.handle = hs.handleConfigGet(parts[1])
+			if req.Method == "PUT" {
+				route.command = "CONFIGSET"
+				route.handle = hs.handleConfigSet(parts[1])
+			}
+			break
+		case "pause":
+			route.command = "PAUSEFUNC"
+			route.handle = hs.handleFuncAction(parts[1], sched.PauseFunc)
+			break
+		case "resume":
+			route.command = "RESUMEFUNC"
+			route.handle = hs.handleFuncAction(parts[1], sched.ResumeFunc)
+			break
+		case "redrive":
+			route.command = "REDRIVEJOB"
+			route.handle = hs.handleFuncAction(parts[1], func(Func string) error {
+				return sched.redriveJob(Func, "")
+			})
+			break
+		case "purge":
+			route.command = "PURGEJOB"
+			route.handle = hs.handleFuncAction(parts[1], func(Func string) error {
+				return sched.purgeJob(Func, "")
+			})
+			break
+		default:
+			return route, newHTTPError(http.StatusNotFound, "Not found.")
+		}
+		break
+	case parts[0] == "workers" && len(parts) == 1:
+		allow = "GET"
+		route.Func = req.URL.Query().Get("func")
+		route.command = "LISTWORKER"
+		route.handle = hs.handleListWorker(route.Func)
+		break
+	case parts[0] == "workers" && len(parts) == 3 && parts[2] == "drain":
+		allow = "POST"
+		route.command = "DRAINWORKER"
+		route.handle = hs.handleDrainWorker(parts[1])
+		break
+	case parts[0] == "events" && len(parts) <= 2:
+		allow = "GET"
+		if len(parts) == 2 {
+			route.Func = parts[1]
+		}
+		route.command = "SUBSCRIBE"
+		route.handle = hs.handleEvents(route.Func)
+		break
+	case parts[0] == "denials" && len(parts) == 1:
+		allow = "GET"
+		route.command = "DENIALS"
+		route.handle = hs.handleDenials
+		break
+	default:
+		return route, newHTTPError(http.StatusNotFound, "Not found.")
+	}
+	for _, method := range strings.Split(allow, ", ") {
+		if method == req.Method {
+			return
+		}
+	}
+	return route, newHTTPError(http.StatusMethodNotAllowed, allow)
+}
+
+func sendJSON(w http.ResponseWriter, status int, data []byte) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+func sendError(w http.ResponseWriter, status int, e error) {
+	data, _ := json.Marshal(map[string]string{"err": e.Error()})
+	sendJSON(w, status, data)
+}
+
+func sendSuccess(w http.ResponseWriter) {
+	sendJSON(w, http.StatusOK, []byte("{\"msg\": \""+protocol.SUCCESS.String()+"\"}"))
+}
+
+// httpJob is the json body to submit a job, wait is the seconds to wait for
+// the result of the job.
+type httpJob struct {
+	Func      string              `json:"func"`
+	Name      string              `json:"name"`
+	Args      string              `json:"workload"`
+	Timeout   int64               `json:"timeout"`
+	SchedAt   int64               `json:"sched_at"`
+	Cron      string              `json:"cron"`
+	Retry     *driver.RetryPolicy `json:"retry"`
+	Priority  int64               `json:"priority"`
+	Selector  map[string]string   `json:"selector"`
+	Broadcast bool                `json:"broadcast"`
+	Wait      int64               `json:"wait"`
+}
+
+// decodeHTTPJob decode the job from the json body, the func in the path is
+// used before the func in the body.
+func decodeHTTPJob(req *http.Request, Func string) (job driver.Job, wait int64, e *httpError) {
+	var body httpJob
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		return job, 0, newHTTPError(http.StatusBadRequest, "Invalid json body: %v", err)
+	}
+	if Func == "" {
+		Func = body.Func
+	}
+	if body.Name == "" || Func == "" {
+		return job, 0, newHTTPError(http.StatusBadRequest, "job name or func is required")
+	}
+	job = driver.Job{
+		Func:      Func,
+		Name:      body.Name,
+		Args:      body.Args,
+		Timeout:   body.Timeout,
+		SchedAt:   body.SchedAt,
+		Cron:      body.Cron,
+		Retry:     body.Retry,
+		Priority:  body.Priority,
+		Selector:  body.Selector,
+		Broadcast: body.Broadcast,
+	}
+	return job, body.Wait, nil
+}
+
+func (hs *httpServer) handleSubmitJob(job driver.Job) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if e := hs.sched.addJob(&job); e != nil {
+			sendError(w, http.StatusBadRequest, e)
+			return
+		}
+		data, _ := json.Marshal(job)
+		sendJSON(w, http.StatusCreated, data)
+	}
+}
+
+func (hs *httpServer) handleSubmitWait(job driver.Job, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		ch, e := hs.sched.addWaitJob(&job)
+		if e != nil {
+			sendError(w, http.StatusBadRequest, e)
+			return
+		}
+		select {
+		case r := <-ch:
+			sendJSON(w, http.StatusOK, r.Bytes())
+			break
+		case <-time.After(timeout):
+			hs.sched.removeWaiter(job.ID, ch)
+			sendError(w, http.StatusGatewayTimeout,
+				fmt.Errorf("Job %s:%s wait timeout.", job.Func, job.Name))
+			break
+		case <-req.Context().Done():
+			hs.sched.removeWaiter(job.ID, ch)
+			break
+		}
 	}
-	sched.notifyJobTimer()
-	c.sendResponse("200 OK", []byte("{\"msg\": \""+protocol.SUCCESS.String()+"\"}"))
-	return
 }
 
 type sstat struct {
@@ -146,45 +438,60 @@ type sstat struct {
 	TotalWorker int    `json:"total_worker"`
 	TotalJob    int    `json:"total_job"`
 	Processing  int    `json:"processing"`
+	RateLimit   string `json:"rate_limit"`
+	RateTokens  int    `json:"rate_tokens"`
+	Paused      bool   `json:"paused"`
 }
 
-func (c *httpClient) handleStatus(funcName string) {
-	defer c.sched.funcLocker.Unlock()
-	c.sched.funcLocker.Lock()
-	var stats = make(map[string]sstat)
-	for _, st := range c.sched.stats {
-		stats[st.Name] = sstat{
-			FuncName:    st.Name,
-			TotalWorker: st.Worker.Int(),
-			TotalJob:    st.Job.Int(),
-			Processing:  st.Processing.Int(),
+func (hs *httpServer) handleStatus(funcName string) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		sched := hs.sched
+		sched.funcLocker.Lock()
+		var stats = make(map[string]sstat)
+		for _, st := range sched.stats {
+			s := sstat{
+				FuncName:    st.Name,
+				TotalWorker: st.Worker.Int(),
+				TotalJob:    st.Job.Int(),
+				Processing:  st.Processing.Int(),
+				Paused:      sched.isPaused(st.Name),
+			}
+			s.RateLimit, s.RateTokens, _ = sched.rateLimitState(st.Name)
+			stats[st.Name] = s
 		}
-	}
-	var data = []byte("{}")
-	if funcName == "" {
-		data, _ = json.Marshal(stats)
-	} else {
-		if _, ok := stats[funcName]; ok {
-			data, _ = json.Marshal(stats[funcName])
+		sched.funcLocker.Unlock()
+		var data []byte
+		if funcName == "" {
+			data, _ = json.Marshal(stats)
+		} else {
+			stat, ok := stats[funcName]
+			if !ok {
+				sendError(w, http.StatusNotFound, fmt.Errorf("Func %s not exists.", funcName))
+				return
+			}
+			data, _ = json.Marshal(stat)
 		}
+		sendJSON(w, http.StatusOK, data)
 	}
-	c.sendResponse("200 OK", data)
-	return
 }
 
-func (c *httpClient) handleDropFunc(funcName string) {
-	if funcName == "" {
-		c.sendErrResponse(errors.New("func is required"))
-		return
-	}
-	sched := c.sched
-	defer sched.notifyJobTimer()
-	defer sched.jobLocker.Unlock()
-	sched.jobLocker.Lock()
-	defer sched.funcLocker.Unlock()
-	sched.funcLocker.Lock()
-	stat, ok := sched.stats[funcName]
-	if ok && stat.Worker.Int() == 0 {
+func (hs *httpServer) handleDropFunc(funcName string) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		sched := hs.sched
+		defer sched.notifyJobTimer()
+		defer sched.jobLocker.Unlock()
+		sched.jobLocker.Lock()
+		defer sched.funcLocker.Unlock()
+		sched.funcLocker.Lock()
+		stat, ok := sched.stats[funcName]
+		if !ok {
+			sendError(w, http.StatusNotFound, fmt.Errorf("Func %s not exists.", funcName))
+			return
+		}
+		if stat.Worker.Int() > 0 {
+			sendError(w, http.StatusConflict, fmt.Errorf("Func %s has workers.", funcName))
+			return
+		}
 		iter := sched.driver.NewIterator([]byte(funcName))
 		var deleteJob = make([]int64, 0)
 		for {
@@ -192,6 +499,9 @@ func (c *httpClient) handleDropFunc(funcName string) {
 				break
 			}
 			job := iter.Value()
+			if job.Func != funcName {
+				continue
+			}
 			deleteJob = append(deleteJob, job.ID)
 		}
 		iter.Close()
@@ -200,40 +510,176 @@ func (c *httpClient) handleDropFunc(funcName string) {
 		}
 		delete(sched.stats, funcName)
 		delete(sched.jobPQ, funcName)
+		delete(sched.readyPQ, funcName)
+		sendSuccess(w)
+	}
+}
+
+// getJob returns the job of func with name, false when the job not exists.
+// The jobLocker must be held.
+func (hs *httpServer) getJob(funcName, name string) (driver.Job, bool) {
+	job, e := hs.sched.driver.GetOne(funcName, name)
+	return job, e == nil && job.ID > 0
+}
+
+func (hs *httpServer) handleGetJob(funcName, name string) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		hs.sched.jobLocker.Lock()
+		job, ok := hs.getJob(funcName, name)
+		hs.sched.jobLocker.Unlock()
+		if !ok {
+			sendError(w, http.StatusNotFound, fmt.Errorf("Job %s:%s not exists.", funcName, name))
+			return
+		}
+		data, _ := json.Marshal(job)
+		sendJSON(w, http.StatusOK, data)
+	}
+}
+
+func (hs *httpServer) handleRemoveJob(funcName, name string) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		sched := hs.sched
+		defer sched.jobLocker.Unlock()
+		sched.jobLocker.Lock()
+		job, ok := hs.getJob(funcName, name)
+		if !ok {
+			sendError(w, http.StatusNotFound, fmt.Errorf("Job %s:%s not exists.", funcName, name))
+			return
+		}
+		sched.deleteJob(job)
+		sched.emit("removed", job, "")
+		sendSuccess(w)
+	}
+}
+
+// handleJobAction run the action on an exists job, the action fail on the
+// job status is a conflict.
+func (hs *httpServer) handleJobAction(funcName, name string, action func(string, string) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		hs.sched.jobLocker.Lock()
+		_, ok := hs.getJob(funcName, name)
+		hs.sched.jobLocker.Unlock()
+		if !ok {
+			sendError(w, http.StatusNotFound, fmt.Errorf("Job %s:%s not exists.", funcName, name))
+			return
+		}
+		if e := action(funcName, name); e != nil {
+			sendError(w, http.StatusConflict, e)
+			return
+		}
+		sendSuccess(w)
+	}
+}
+
+// httpConfig is the json body to set a config of func
+type httpConfig struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+func (hs *httpServer) handleConfigSet(funcName string) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		var cfg httpConfig
+		if e := json.NewDecoder(req.Body).Decode(&cfg); e != nil {
+			sendError(w, http.StatusBadRequest, fmt.Errorf("Invalid json body: %v", e))
+			return
+		}
+		if e := hs.sched.setFuncConfig(funcName, cfg.Key, cfg.Value); e != nil {
+			sendError(w, http.StatusBadRequest, e)
+			return
+		}
+		sendSuccess(w)
+	}
+}
+
+func (hs *httpServer) handleConfigGet(funcName string) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		data, _ := json.Marshal(hs.sched.getFuncConfig(funcName))
+		sendJSON(w, http.StatusOK, data)
+	}
+}
+
+func (hs *httpServer) handleListJob(funcName string) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		data, _ := json.Marshal(hs.sched.listJobs(funcName, req.URL.Query().Get("status")))
+		sendJSON(w, http.StatusOK, data)
+	}
+}
+
+func (hs *httpServer) handleListWorker(funcName string) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		data, _ := json.Marshal(hs.sched.listWorkers(funcName))
+		sendJSON(w, http.StatusOK, data)
 	}
-	c.sendResponse("200 OK", []byte("{\"msg\": \""+protocol.SUCCESS.String()+"\"}"))
-	return
 }
 
-func (c *httpClient) handleRemoveJob(req *http.Request) {
-	var job driver.Job
-	var e error
-	var sched = c.sched
-	defer sched.jobLocker.Unlock()
-	sched.jobLocker.Lock()
-	url := req.URL.String()
-	funcName := url[1:]
-	if funcName == "" {
-		funcName = req.FormValue("func")
+func (hs *httpServer) handleFuncAction(funcName string, action func(string) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if e := action(funcName); e != nil {
+			sendError(w, http.StatusBadRequest, e)
+			return
+		}
+		sendSuccess(w)
 	}
-	name := req.FormValue("name")
-	job, e = sched.driver.GetOne(funcName, name)
-	if e == nil && job.ID > 0 {
-		if _, ok := sched.procQueue[job.ID]; ok {
-			delete(sched.procQueue, job.ID)
+}
+
+func (hs *httpServer) handleDrainWorker(name string) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if e := hs.sched.drainWorkers(name); e != nil {
+			sendError(w, http.StatusNotFound, e)
+			return
 		}
-		sched.driver.Delete(job.ID)
-		sched.decrStatJob(job)
-		if job.IsProc() {
-			sched.decrStatProc(job)
-			sched.removeRevertPQ(job)
+		sendSuccess(w)
+	}
+}
+
+func (hs *httpServer) handleGetResult(funcName, name string) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		r, e := hs.sched.jobResult(funcName, name)
+		if e != nil {
+			sendError(w, http.StatusNotFound, e)
+			return
 		}
-		sched.notifyJobTimer()
+		sendJSON(w, http.StatusOK, r.Bytes())
 	}
+}
 
-	if e != nil {
-		c.sendErrResponse(e)
-	} else {
-		c.sendResponse("200 OK", []byte("{\"msg\": \""+protocol.SUCCESS.String()+"\"}"))
+func (hs *httpServer) handleDenials(w http.ResponseWriter, req *http.Request) {
+	data, _ := json.Marshal(hs.sched.Denials())
+	sendJSON(w, http.StatusOK, data)
+}
+
+// handleEvents stream the job events of func as Server-Sent Events
+func (hs *httpServer) handleEvents(funcName string) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			sendError(w, http.StatusInternalServerError, errors.New("streaming unsupported"))
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+		sub := hs.sched.subscribe(funcName)
+		defer hs.sched.unsubscribe(sub)
+		ticker := time.NewTicker(30 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case ev, ok := <-sub.ch:
+				if !ok {
+					return
+				}
+				fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, ev.Bytes())
+				break
+			case <-ticker.C:
+				fmt.Fprint(w, ": ping\n\n")
+				break
+			case <-req.Context().Done():
+				return
+			}
+			flusher.Flush()
+		}
 	}
 }
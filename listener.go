@@ -0,0 +1,146 @@
+package periodic
+
+import (
+	"crypto/tls"
+	"fmt"
+	"github.com/felixge/tcpkeepalive"
+	"log"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// listener defined an entry point of periodic with its options.
+// The options are set in the query of the entry point, eg:
+// tcp://0.0.0.0:5000?timeout=30&tls=true&auth=false&http=true
+type listener struct {
+	entryPoint string
+	network    string
+	address    string
+	timeout    time.Duration // The connection deadline, 0 is no deadline
+	tls        bool
+	auth       bool // The connections must be authenticated when auth is set
+	http       bool // Only serve the http api, the binary protocol is not accepted
+	listen     net.Listener
+	httpServer *httpServer
+}
+
+// parseEntryPoints parse the entry points, the empty ones are skipped
+func (sched *Sched) parseEntryPoints(entryPoints []string) ([]*listener, error) {
+	var listeners = make([]*listener, 0)
+	for _, entryPoint := range entryPoints {
+		entryPoint = strings.TrimSpace(entryPoint)
+		if entryPoint == "" {
+			continue
+		}
+		l, err := sched.parseEntryPoint(entryPoint)
+		if err != nil {
+			return nil, err
+		}
+		listeners = append(listeners, l)
+	}
+	if len(listeners) == 0 {
+		return nil, fmt.Errorf("no entry point")
+	}
+	return listeners, nil
+}
+
+func (sched *Sched) parseEntryPoint(entryPoint string) (l *listener, err error) {
+	u, err := url.Parse(entryPoint)
+	if err != nil {
+		return
+	}
+	l = &listener{
+		entryPoint: entryPoint,
+		network:    u.Scheme,
+		address:    u.Host + u.Path,
+		timeout:    sched.timeout * time.Second,
+		auth:       true,
+	}
+	query := u.Query()
+	switch u.Scheme {
+	case "unix":
+		break
+	case "tcp":
+		// the tcp entry point is served with TLS when the certificate is set
+		l.tls = sched.tls != nil
+		break
+	case "tls":
+		l.network = "tcp"
+		l.tls = true
+		break
+	default:
+		return nil, fmt.Errorf("%s: unsupported network %s", entryPoint, u.Scheme)
+	}
+	if v := query.Get("timeout"); v != "" {
+		timeout, err := strconv.Atoi(v)
+		if err != nil || timeout < 0 {
+			return nil, fmt.Errorf("%s: invalid timeout %s", entryPoint, v)
+		}
+		l.timeout = time.Duration(timeout) * time.Second
+	}
+	if v := query.Get("tls"); v != "" {
+		if l.tls, err = strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf("%s: invalid tls %s", entryPoint, v)
+		}
+	}
+	if v := query.Get("auth"); v != "" {
+		if l.auth, err = strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf("%s: invalid auth %s", entryPoint, v)
+		}
+	}
+	if v := query.Get("http"); v != "" {
+		if l.http, err = strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf("%s: invalid http %s", entryPoint, v)
+		}
+	}
+	if l.tls && sched.tls == nil {
+		return nil, fmt.Errorf("%s: the certificate is required", entryPoint)
+	}
+	if l.tls && l.network != "tcp" {
+		return nil, fmt.Errorf("%s: tls is only for tcp", entryPoint)
+	}
+	return l, nil
+}
+
+// authenticate returns the identity of the token, the connection without a
+// valid token is anonymous when the listener not require auth.
+func (l *listener) authenticate(sched *Sched, token string) (identity string, ok bool) {
+	identity, ok = sched.authenticate(token)
+	if !ok && !l.auth {
+		return "", true
+	}
+	return
+}
+
+func (l *listener) serve(sched *Sched) {
+	for {
+		if !sched.alive {
+			break
+		}
+		conn, err := l.listen.Accept()
+		if err != nil {
+			if !sched.alive {
+				break
+			}
+			log.Fatal(err)
+		}
+		var deadline time.Time
+		if l.timeout > 0 {
+			deadline = time.Now().Add(l.timeout)
+			conn.SetDeadline(deadline)
+		}
+		if l.network == "tcp" {
+			kaConn, _ := tcpkeepalive.EnableKeepAlive(conn)
+			kaConn.SetKeepAliveIdle(30 * time.Second)
+			kaConn.SetKeepAliveCount(4)
+			kaConn.SetKeepAliveInterval(5 * time.Second)
+		}
+		if l.tls {
+			conn = tls.Server(conn, sched.tls.serverConfig())
+		}
+		go sched.handleConnection(conn, l, deadline)
+	}
+}
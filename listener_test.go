@@ -0,0 +1,105 @@
+package periodic
+
+import (
+	"github.com/Lupino/periodic/driver"
+	"testing"
+	"time"
+)
+
+func TestParseEntryPoint(t *testing.T) {
+	sched := NewSched(nil, driver.NewMemStroeDriver(), 10)
+	var tests = []struct {
+		entryPoint string
+		network    string
+		address    string
+		timeout    time.Duration
+		tls        bool
+		auth       bool
+		http       bool
+	}{
+		{"unix:///tmp/periodic.sock", "unix", "/tmp/periodic.sock", 10 * time.Second, false, true, false},
+		{"tcp://127.0.0.1:5000", "tcp", "127.0.0.1:5000", 10 * time.Second, false, true, false},
+		{"tcp://127.0.0.1:5000?timeout=0", "tcp", "127.0.0.1:5000", 0, false, true, false},
+		{"tcp://127.0.0.1:5000?timeout=30&auth=false", "tcp", "127.0.0.1:5000", 30 * time.Second, false, false, false},
+		{"tcp://127.0.0.1:5000?http=true", "tcp", "127.0.0.1:5000", 10 * time.Second, false, true, true},
+	}
+	for _, test := range tests {
+		l, err := sched.parseEntryPoint(test.entryPoint)
+		if err != nil {
+			t.Fatalf("parseEntryPoint %s: %s\n", test.entryPoint, err)
+		}
+		if l.network != test.network || l.address != test.address {
+			t.Fatalf("parseEntryPoint %s: except: %s %s, got: %s %s\n", test.entryPoint, test.network, test.address, l.network, l.address)
+		}
+		if l.timeout != test.timeout {
+			t.Fatalf("parseEntryPoint %s timeout: except: %s, got: %s\n", test.entryPoint, test.timeout, l.timeout)
+		}
+		if l.tls != test.tls || l.auth != test.auth || l.http != test.http {
+			t.Fatalf("parseEntryPoint %s: except: tls=%v auth=%v http=%v, got: tls=%v auth=%v http=%v\n",
+				test.entryPoint, test.tls, test.auth, test.http, l.tls, l.auth, l.http)
+		}
+	}
+}
+
+func TestParseEntryPointTLS(t *testing.T) {
+	sched := NewSched(nil, driver.NewMemStroeDriver(), 0)
+	sched.tls = &tlsFiles{}
+	var tests = []struct {
+		entryPoint string
+		tls        bool
+	}{
+		{"tls://127.0.0.1:5000", true},
+		{"tcp://127.0.0.1:5000", true},
+		{"tcp://127.0.0.1:5000?tls=false", false},
+		{"unix:///tmp/periodic.sock", false},
+	}
+	for _, test := range tests {
+		l, err := sched.parseEntryPoint(test.entryPoint)
+		if err != nil {
+			t.Fatalf("parseEntryPoint %s: %s\n", test.entryPoint, err)
+		}
+		if l.tls != test.tls {
+			t.Fatalf("parseEntryPoint %s tls: except: %v, got: %v\n", test.entryPoint, test.tls, l.tls)
+		}
+	}
+	if _, err := sched.parseEntryPoint("unix:///tmp/periodic.sock?tls=true"); err == nil {
+		t.Fatalf("parseEntryPoint tls on unix: except error, got nil\n")
+	}
+}
+
+func TestParseEntryPointError(t *testing.T) {
+	sched := NewSched(nil, driver.NewMemStroeDriver(), 0)
+	var entryPoints = []string{
+		"udp://127.0.0.1:5000",
+		"127.0.0.1:5000",
+		"tcp://127.0.0.1:5000?timeout=abc",
+		"tcp://127.0.0.1:5000?timeout=-1",
+		"tcp://127.0.0.1:5000?tls=abc",
+		"tcp://127.0.0.1:5000?auth=abc",
+		"tcp://127.0.0.1:5000?http=abc",
+		"tls://127.0.0.1:5000",
+		"tcp://127.0.0.1:5000?tls=true",
+		"%zz",
+	}
+	for _, entryPoint := range entryPoints {
+		if _, err := sched.parseEntryPoint(entryPoint); err == nil {
+			t.Fatalf("parseEntryPoint %s: except error, got nil\n", entryPoint)
+		}
+	}
+}
+
+func TestParseEntryPoints(t *testing.T) {
+	sched := NewSched(nil, driver.NewMemStroeDriver(), 0)
+	listeners, err := sched.parseEntryPoints([]string{"unix:///tmp/periodic.sock", " tcp://127.0.0.1:5000?x=a,b", ""})
+	if err != nil {
+		t.Fatalf("parseEntryPoints: %s\n", err)
+	}
+	if len(listeners) != 2 || listeners[0].network != "unix" || listeners[1].network != "tcp" {
+		t.Fatalf("parseEntryPoints: except: unix tcp, got: %d listeners\n", len(listeners))
+	}
+	for _, entryPoints := range [][]string{nil, {" "}, {"unix:///tmp/periodic.sock", "udp://127.0.0.1:5000"}} {
+		if _, err := sched.parseEntryPoints(entryPoints); err == nil {
+			t.Fatalf("parseEntryPoints %q: except error, got nil\n", entryPoints)
+		}
+	}
+}
@@ -1,9 +1,14 @@
 package queue
 
+import (
+	"math"
+)
+
 // An Item is something we manage in a priority queue.
 type Item struct {
 	Value    int64 // The value of the item; arbitrary.
 	Priority int64 // The priority of the item in the queue.
+	Rank     int64 // The rank of a due item in the ReadyQueue, higher is served first.
 	// The index is needed by update and is maintained by the heap.Interface methods.
 	Index int // The index of the item in the heap.
 }
@@ -52,3 +57,30 @@ func (pq *PriorityQueue) Get(value int64) *Item {
 	}
 	return nil
 }
+
+// A ReadyQueue implements heap.Interface and holds the due Items,
+// the higher Rank is served first then the lower Priority.
+type ReadyQueue struct {
+	PriorityQueue
+}
+
+// Less reports whether the item with index i should sort before the item with index j.
+func (rq ReadyQueue) Less(i, j int) bool {
+	// all the items in the ReadyQueue are due
+	return Less(rq.PriorityQueue[i], rq.PriorityQueue[j], math.MaxInt64)
+}
+
+// Less reports whether item a should be served before item b at now.
+// The due items (Priority <= now) come first, ordered by Rank then Priority,
+// the others are ordered by Priority.
+func Less(a, b *Item, now int64) bool {
+	aDue := a.Priority <= now
+	bDue := b.Priority <= now
+	if aDue != bDue {
+		return aDue
+	}
+	if aDue && a.Rank != b.Rank {
+		return a.Rank > b.Rank
+	}
+	return a.Priority < b.Priority
+}
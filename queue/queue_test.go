@@ -31,3 +31,33 @@ func TestQueue(t *testing.T) {
 		fmt.Printf("%d ", item.Priority)
 	}
 }
+
+func TestReadyQueue(t *testing.T) {
+	rq := new(ReadyQueue)
+	heap.Init(rq)
+	heap.Push(rq, &Item{Value: 1, Priority: 10, Rank: 0})
+	heap.Push(rq, &Item{Value: 2, Priority: 30, Rank: 5})
+	heap.Push(rq, &Item{Value: 3, Priority: 20, Rank: 5})
+	heap.Push(rq, &Item{Value: 4, Priority: 5, Rank: -1})
+	for _, except := range []int64{3, 2, 1, 4} {
+		item := heap.Pop(rq).(*Item)
+		if item.Value != except {
+			t.Fatalf("ReadyQueue: except: %d, got: %d\n", except, item.Value)
+		}
+	}
+}
+
+func TestLess(t *testing.T) {
+	due := &Item{Value: 1, Priority: 10, Rank: 0}
+	urgent := &Item{Value: 2, Priority: 20, Rank: 9}
+	future := &Item{Value: 3, Priority: 40, Rank: 99}
+	if !Less(urgent, due, 30) {
+		t.Fatalf("Less: except: the higher rank due item first\n")
+	}
+	if !Less(due, future, 30) {
+		t.Fatalf("Less: except: the due item first\n")
+	}
+	if !Less(due, urgent, 5) {
+		t.Fatalf("Less: except: the earlier item first when none is due\n")
+	}
+}
@@ -0,0 +1,103 @@
+package ratelimit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var units = map[string]time.Duration{
+	"s": time.Second,
+	"m": time.Minute,
+	"h": time.Hour,
+}
+
+// Bucket defined a token bucket, allow limit tokens per duration
+type Bucket struct {
+	limit  int
+	per    time.Duration
+	tokens float64
+	last   time.Time
+	locker *sync.Mutex
+}
+
+// NewBucket create a full token bucket
+func NewBucket(limit int, per time.Duration) *Bucket {
+	var b = new(Bucket)
+	b.limit = limit
+	b.per = per
+	b.tokens = float64(limit)
+	b.last = time.Now()
+	b.locker = new(sync.Mutex)
+	return b
+}
+
+// Parse create a token bucket from spec like 100/m, 10/s or 1000/h
+func Parse(spec string) (*Bucket, error) {
+	parts := strings.SplitN(strings.TrimSpace(spec), "/", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("ratelimit: invalid spec %q", spec)
+	}
+	limit, err := strconv.Atoi(parts[0])
+	if err != nil || limit < 1 {
+		return nil, fmt.Errorf("ratelimit: invalid limit %q", parts[0])
+	}
+	per, ok := units[parts[1]]
+	if !ok {
+		return nil, fmt.Errorf("ratelimit: invalid unit %q", parts[1])
+	}
+	return NewBucket(limit, per), nil
+}
+
+func (b *Bucket) refill(now time.Time) {
+	if now.After(b.last) {
+		b.tokens = b.tokens + float64(now.Sub(b.last))*float64(b.limit)/float64(b.per)
+		if b.tokens > float64(b.limit) {
+			b.tokens = float64(b.limit)
+		}
+	}
+	b.last = now
+}
+
+// Wait returns the duration until the next token is available, 0 when a token
+// is available now.
+func (b *Bucket) Wait(now time.Time) time.Duration {
+	defer b.locker.Unlock()
+	b.locker.Lock()
+	b.refill(now)
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) * float64(b.per) / float64(b.limit))
+}
+
+// Take a token, returns false when no token is available.
+func (b *Bucket) Take(now time.Time) bool {
+	defer b.locker.Unlock()
+	b.locker.Lock()
+	b.refill(now)
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens = b.tokens - 1
+	return true
+}
+
+// Tokens returns the available tokens
+func (b *Bucket) Tokens(now time.Time) int {
+	defer b.locker.Unlock()
+	b.locker.Lock()
+	b.refill(now)
+	return int(b.tokens)
+}
+
+func (b *Bucket) String() string {
+	for unit, per := range units {
+		if per == b.per {
+			return strconv.Itoa(b.limit) + "/" + unit
+		}
+	}
+	return strconv.Itoa(b.limit) + "/" + b.per.String()
+}
@@ -0,0 +1,42 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBucket(t *testing.T) {
+	var b, err = Parse("2/s")
+	if err != nil {
+		t.Fatalf("Parse: %s\n", err)
+	}
+	var now = b.last
+	if !b.Take(now) || !b.Take(now) {
+		t.Fatalf("Take: except: true, got: false\n")
+	}
+	if b.Take(now) {
+		t.Fatalf("Take: except: false, got: true\n")
+	}
+	if w := b.Wait(now); w != 500*time.Millisecond {
+		t.Fatalf("Wait: except: 500ms, got: %s\n", w)
+	}
+	now = now.Add(500 * time.Millisecond)
+	if w := b.Wait(now); w != 0 {
+		t.Fatalf("Wait: except: 0, got: %s\n", w)
+	}
+	now = now.Add(time.Minute)
+	if v := b.Tokens(now); v != 2 {
+		t.Fatalf("Tokens: except: 2, got: %d\n", v)
+	}
+	if b.String() != "2/s" {
+		t.Fatalf("String: except: 2/s, got: %s\n", b)
+	}
+}
+
+func TestParseError(t *testing.T) {
+	for _, spec := range []string{"", "10", "0/s", "-1/m", "10/d", "a/s"} {
+		if _, err := Parse(spec); err == nil {
+			t.Fatalf("Parse %q: except error, got nil\n", spec)
+		}
+	}
+}
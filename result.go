@@ -0,0 +1,63 @@
+package periodic
+
+import (
+	"fmt"
+	"github.com/Lupino/periodic/driver"
+	"log"
+	"time"
+)
+
+// SetResultRetention set how long the job results are kept, 0 to not keep
+// the results.
+func (sched *Sched) SetResultRetention(retention time.Duration) {
+	sched.resultRetention = retention
+}
+
+// saveResult keep the result of a finished job and send it to the job waiters
+func (sched *Sched) saveResult(job driver.Job, status string, data []byte) {
+	now := time.Now()
+	r := driver.Result{
+		Func:   job.Func,
+		Name:   job.Name,
+		Status: status,
+		Data:   string(data),
+		DoneAt: now.Unix(),
+	}
+	sched.notifyWaiters(job.ID, r)
+	if sched.resultRetention <= 0 {
+		return
+	}
+	r.ExpireAt = now.Add(sched.resultRetention).Unix()
+	if err := sched.driver.SaveResult(r); err != nil {
+		log.Printf("Save result of job %s:%s error: %v\n", job.Func, job.Name, err)
+	}
+}
+
+// jobResult returns the result of a job with func and name, the status of
+// the job when it is not finished yet.
+func (sched *Sched) jobResult(Func, name string) (driver.Result, error) {
+	if r, err := sched.driver.GetResult(Func, name); err == nil {
+		return r, nil
+	}
+	job, err := sched.driver.GetOne(Func, name)
+	if err != nil || job.ID == 0 {
+		return driver.Result{}, fmt.Errorf("Result %s:%s not exists.", Func, name)
+	}
+	return driver.Result{
+		Func:   job.Func,
+		Name:   job.Name,
+		Status: job.Status,
+	}, nil
+}
+
+func (sched *Sched) handleExpireResults() {
+	for {
+		if !sched.alive {
+			break
+		}
+		time.Sleep(time.Minute)
+		if err := sched.driver.ExpireResults(time.Now().Unix()); err != nil {
+			log.Printf("Expire results error: %v\n", err)
+		}
+	}
+}
@@ -1,43 +1,65 @@
 package periodic
 
 import (
-	"bytes"
+	"bufio"
 	"container/heap"
+	"fmt"
 	"github.com/Lupino/go-periodic/protocol"
 	"github.com/Lupino/periodic/driver"
 	"github.com/Lupino/periodic/queue"
 	"github.com/Lupino/periodic/stat"
-	"github.com/felixge/tcpkeepalive"
 	"io"
 	"log"
 	"net"
-	"strings"
+	"sort"
 	"sync"
 	"time"
 )
 
 // Sched defined periodic schedule
 type Sched struct {
-	jobTimer    *time.Timer
-	grabQueue   *grabQueue
-	procQueue   map[int64]driver.Job
-	revertPQ    queue.PriorityQueue
-	revTimer    *time.Timer
-	entryPoint  string
-	jobLocker   *sync.Mutex
-	timerLocker *sync.Mutex
-	stats       map[string]*stat.FuncStat
-	funcLocker  *sync.Mutex
-	driver      driver.StoreDriver
-	jobPQ       map[string]*queue.PriorityQueue
-	PQLocker    *sync.Mutex
-	timeout     time.Duration
-	alive       bool
-	cacheItem   *queue.Item
-}
-
-// NewSched create an instance of periodic schedule
-func NewSched(entryPoint string, store driver.StoreDriver, timeout time.Duration) *Sched {
+	jobTimer        *time.Timer
+	grabQueue       *grabQueue
+	procQueue       map[int64]driver.Job
+	revertPQ        queue.PriorityQueue
+	revTimer        *time.Timer
+	entryPoints     []string
+	jobLocker       *sync.Mutex
+	timerLocker     *sync.Mutex
+	stats           map[string]*stat.FuncStat
+	funcLocker      *sync.Mutex
+	driver          driver.StoreDriver
+	jobPQ           map[string]*queue.PriorityQueue
+	readyPQ         map[string]*queue.ReadyQueue
+	PQLocker        *sync.Mutex
+	timeout         time.Duration
+	alive           bool
+	cacheItem       *queue.Item
+	configs         map[string]*funcConfig
+	configLocker    *sync.Mutex
+	tokenWait       time.Duration
+	policy          DispatchPolicy
+	resultRetention time.Duration
+	waiters         map[int64][]chan driver.Result
+	waitLocker      *sync.Mutex
+	subscribers     map[*subscriber]bool
+	subLocker       *sync.Mutex
+	workers         map[*worker]bool
+	workerLocker    *sync.Mutex
+	workerGroup     *sync.WaitGroup // The running worker connections
+	parked          map[string]map[int64]driver.Job
+	broadcasts      map[int64]*broadcast
+	listeners       []*listener
+	listenerLocker  *sync.Mutex
+	shutdownTimeout time.Duration
+	tls             *tlsFiles
+	auth            *authTokens
+	acl             *acl
+}
+
+// NewSched create an instance of periodic schedule, served on all the entry
+// points.
+func NewSched(entryPoints []string, store driver.StoreDriver, timeout time.Duration) *Sched {
 	sched := new(Sched)
 	sched.jobTimer = time.NewTimer(1 * time.Hour)
 	sched.revTimer = time.NewTimer(1 * time.Hour)
@@ -45,7 +67,7 @@ func NewSched(entryPoint string, store driver.StoreDriver, timeout time.Duration
 	sched.procQueue = make(map[int64]driver.Job)
 	sched.revertPQ = make(queue.PriorityQueue, 0)
 	heap.Init(&sched.revertPQ)
-	sched.entryPoint = entryPoint
+	sched.entryPoints = entryPoints
 	sched.jobLocker = new(sync.Mutex)
 	sched.PQLocker = new(sync.Mutex)
 	sched.funcLocker = new(sync.Mutex)
@@ -53,48 +75,64 @@ func NewSched(entryPoint string, store driver.StoreDriver, timeout time.Duration
 	sched.stats = make(map[string]*stat.FuncStat)
 	sched.driver = store
 	sched.jobPQ = make(map[string]*queue.PriorityQueue)
+	sched.readyPQ = make(map[string]*queue.ReadyQueue)
 	sched.timeout = timeout
 	sched.alive = true
 	sched.cacheItem = nil
+	sched.configs = make(map[string]*funcConfig)
+	sched.configLocker = new(sync.Mutex)
+	sched.policy = earliestPolicy{}
+	sched.resultRetention = time.Hour
+	sched.waiters = make(map[int64][]chan driver.Result)
+	sched.waitLocker = new(sync.Mutex)
+	sched.subscribers = make(map[*subscriber]bool)
+	sched.subLocker = new(sync.Mutex)
+	sched.workers = make(map[*worker]bool)
+	sched.workerLocker = new(sync.Mutex)
+	sched.workerGroup = new(sync.WaitGroup)
+	sched.parked = make(map[string]map[int64]driver.Job)
+	sched.broadcasts = make(map[int64]*broadcast)
+	sched.listenerLocker = new(sync.Mutex)
+	sched.shutdownTimeout = 30 * time.Second
 	return sched
 }
 
-// Serve of periodic
+// Serve of periodic, all the entry points feed the same schedule.
 func (sched *Sched) Serve() {
-	parts := strings.SplitN(sched.entryPoint, "://", 2)
-	isTCP := true
-	if parts[0] == "unix" {
-		sockCheck(parts[1])
-		isTCP = false
+	listeners, err := sched.parseEntryPoints(sched.entryPoints)
+	if err != nil {
+		log.Fatal(err)
 	}
+	sched.loadFuncConfigs()
+	sched.loadPausedFuncs()
 	sched.loadJobQueue()
 	go sched.handleJobPQ()
 	go sched.handleRevertPQ()
-	listen, err := net.Listen(parts[0], parts[1])
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer listen.Close()
-	log.Printf("Periodic task system started on %s\n", sched.entryPoint)
-	for {
-		if !sched.alive {
-			break
+	go sched.handleExpireResults()
+	for _, l := range listeners {
+		if l.network == "unix" {
+			sockCheck(l.address)
 		}
-		conn, err := listen.Accept()
-		if err != nil {
+		if l.listen, err = net.Listen(l.network, l.address); err != nil {
 			log.Fatal(err)
 		}
-		if sched.timeout > 0 {
-			conn.SetDeadline(time.Now().Add(sched.timeout * time.Second))
-		}
-		if isTCP {
-			kaConn, _ := tcpkeepalive.EnableKeepAlive(conn)
-			kaConn.SetKeepAliveIdle(30 * time.Second)
-			kaConn.SetKeepAliveCount(4)
-			kaConn.SetKeepAliveInterval(5 * time.Second)
-		}
-		go sched.handleConnection(conn)
+		defer l.listen.Close()
+		l.httpServer = newHTTPServer(sched, l)
+		go l.httpServer.serve()
+		log.Printf("Periodic task system started on %s\n", l.entryPoint)
+	}
+	sched.listenerLocker.Lock()
+	sched.listeners = listeners
+	sched.listenerLocker.Unlock()
+	var wg sync.WaitGroup
+	for _, l := range listeners {
+		wg.Add(1)
+		go func(l *listener) {
+			defer wg.Done()
+			l.serve(sched)
+		}(l)
 	}
+	wg.Wait()
 }
 
 func (sched *Sched) notifyJobTimer() {
@@ -117,20 +155,30 @@ func (sched *Sched) resetRevertTimer(d time.Duration) {
 	sched.revTimer.Reset(d)
 }
 
-func (sched *Sched) handleConnection(conn net.Conn) {
-	c := protocol.NewServerConn(conn)
-	payload, err := c.Receive()
-	c.Send([]byte("OK"))
-	if len(payload) == 4 {
-		if bytes.Contains([]byte("GET ,POST,DELETE,PUT "), payload) {
-			httpclient := newHTTPClient(sched, c)
-			httpclient.handle(payload)
-		} else {
-			log.Printf("Unsupport protocol %v\n", payload)
-			c.Close()
+// handleConnection serve the connection accepted by the listener, the
+// deadline is the listener timeout, zero for no timeout.
+func (sched *Sched) handleConnection(conn net.Conn, l *listener, deadline time.Time) {
+	if l.http {
+		l.httpServer.serveConn(conn)
+		return
+	}
+	// the http requests on the entry point are served by the http api
+	reader := bufio.NewReader(conn)
+	head, err := reader.Peek(4)
+	if err != nil {
+		if err != io.EOF {
+			log.Printf("Connection Error: %v\n", err)
 		}
+		conn.Close()
 		return
 	}
+	conn = &bufferedConn{conn, reader}
+	if isHTTPRequest(head) {
+		l.httpServer.serveConn(conn)
+		return
+	}
+	c := protocol.NewServerConn(conn)
+	payload, err := c.Receive()
 	if err != nil {
 		if err != io.EOF {
 			log.Printf("Connection Error: %v, %v\n", err, payload)
@@ -138,14 +186,37 @@ func (sched *Sched) handleConnection(conn net.Conn) {
 		}
 		return
 	}
+	if len(payload) == 0 {
+		log.Printf("Empty client type from %s\n", conn.RemoteAddr())
+		c.Send([]byte("Unsupport client"))
+		c.Close()
+		return
+	}
+	// the token is sent after the client type byte
+	identity, ok := l.authenticate(sched, string(payload[1:]))
+	if !ok {
+		log.Printf("Unauthorized connection from %s\n", conn.RemoteAddr())
+		c.Send([]byte("Unauthorized"))
+		c.Close()
+		return
+	}
+	c.Send([]byte("OK"))
 	switch protocol.ClientType(payload[0]) {
 	case protocol.TYPECLIENT:
 		client := newClient(sched, c)
+		client.identity = identity
 		client.handle()
 		break
 	case protocol.TYPEWORKER:
 		w := newWorker(sched, c)
+		w.identity = identity
+		w.deadline = deadline
+		if !sched.addWorker(w) {
+			c.Close()
+			break
+		}
 		w.handle()
+		sched.workerGroup.Done()
 		break
 	default:
 		log.Printf("Unsupport client %d\n", payload[0])
@@ -154,7 +225,79 @@ func (sched *Sched) handleConnection(conn net.Conn) {
 	}
 }
 
-func (sched *Sched) done(jobID int64) {
+// addWorker add the worker to the running workers, false when the schedule
+// is closed.
+func (sched *Sched) addWorker(w *worker) bool {
+	defer sched.workerLocker.Unlock()
+	sched.workerLocker.Lock()
+	if !sched.alive {
+		return false
+	}
+	sched.workers[w] = true
+	sched.workerGroup.Add(1)
+	return true
+}
+
+func (sched *Sched) removeWorker(w *worker) {
+	defer sched.workerLocker.Unlock()
+	sched.workerLocker.Lock()
+	delete(sched.workers, w)
+}
+
+// listWorkers returns the connected workers can do the func, all the workers
+// when Func is empty.
+func (sched *Sched) listWorkers(Func string) []WorkerInfo {
+	var workers = make([]*worker, 0)
+	sched.workerLocker.Lock()
+	for w := range sched.workers {
+		workers = append(workers, w)
+	}
+	sched.workerLocker.Unlock()
+	var infos = make([]WorkerInfo, 0)
+	for _, w := range workers {
+		info := w.info()
+		if Func != "" && !hasFunc(info.Funcs, Func) {
+			continue
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].ConnectedAt < infos[j].ConnectedAt
+	})
+	return infos
+}
+
+// drainWorkers drain the workers with the id or hostname
+func (sched *Sched) drainWorkers(name string) error {
+	var workers = make([]*worker, 0)
+	sched.workerLocker.Lock()
+	for w := range sched.workers {
+		w.locker.Lock()
+		if w.id == name || w.hostname == name {
+			workers = append(workers, w)
+		}
+		w.locker.Unlock()
+	}
+	sched.workerLocker.Unlock()
+	if len(workers) == 0 {
+		return fmt.Errorf("Worker %s not found.", name)
+	}
+	for _, w := range workers {
+		w.drain()
+	}
+	return nil
+}
+
+func hasFunc(funcs []string, Func string) bool {
+	for _, f := range funcs {
+		if f == Func {
+			return true
+		}
+	}
+	return false
+}
+
+func (sched *Sched) done(jobID int64, result []byte) {
 	defer sched.notifyJobTimer()
 	defer sched.notifyRevertTimer()
 	defer sched.jobLocker.Unlock()
@@ -163,12 +306,24 @@ func (sched *Sched) done(jobID int64) {
 		delete(sched.procQueue, jobID)
 	}
 	job, err := sched.driver.Get(jobID)
-	if err == nil {
-		sched.driver.Delete(jobID)
-		sched.decrStatJob(job)
-		sched.decrStatProc(job)
-		sched.removeRevertPQ(job)
+	// the job is reverted already, the late done is ignored
+	if err != nil || !job.IsProc() {
+		return
+	}
+	sched.decrStatProc(job)
+	sched.removeRevertPQ(job)
+	sched.saveResult(job, "done", result)
+	sched.emit("done", job, "")
+	if schedAt, ok := nextCronSchedAt(job); ok {
+		job.SetReady()
+		job.SchedAt = schedAt
+		job.Attempts = 0
+		sched.driver.Save(&job)
+		sched.pushJobPQ(job)
+		return
 	}
+	sched.driver.Delete(jobID)
+	sched.decrStatJob(job)
 	return
 }
 
@@ -182,24 +337,77 @@ func (sched *Sched) submitJob(item grabItem, job driver.Job) bool {
 	if _, ok := sched.procQueue[job.ID]; ok {
 		return true
 	}
+	if !sched.assignJob(item, job) {
+		return false
+	}
+	// fill the other slots of the grab with the due jobs of the func
+	if item.slots > 1 {
+		sched.assignDueJobs(item, job.Func, item.slots-1)
+	}
+	return true
+}
 
-	if !item.w.alive {
+// assignDueJobs assign at most n due jobs of func to the grab in one pass, the
+// jobs can not be assigned are pushed back. The jobLocker must be held.
+func (sched *Sched) assignDueJobs(item grabItem, Func string, n int) {
+	for _, qItem := range sched.popDueItems(Func, n, int64(time.Now().Unix())) {
+		job, err := sched.driver.Get(qItem.Value)
+		if err != nil {
+			continue
+		}
+		if _, ok := sched.procQueue[job.ID]; ok {
+			continue
+		}
+		if job.Name == "" {
+			sched.driver.Delete(job.ID)
+			continue
+		}
+		if job.Broadcast || !item.has(job) || !sched.assignJob(item, job) {
+			sched.pushJobPQ(job)
+			continue
+		}
+		sched.policy.Served(Func)
+	}
+}
+
+// assignJob send the job to the worker of the grab and mark it processing.
+// The jobLocker must be held.
+func (sched *Sched) assignJob(item grabItem, job driver.Job) bool {
+	if !sched.alive || !item.w.alive || item.w.isDraining() {
 		return false
 	}
-	if err := item.w.handleJobAssign(item.msgID, job); err != nil {
-		item.w.alive = false
+	// the job may be pushed or cached before its func paused
+	if sched.isPaused(job.Func) {
+		return false
+	}
+	if sched.reachConcurrency(sched.getFuncStat(job.Func)) {
+		return false
+	}
+	if sched.rateLimitWait(job.Func) > 0 {
 		return false
 	}
 	now := time.Now()
 	current := int64(now.Unix())
 	job.SetProc()
 	job.RunAt = current
+	job.LeaseUntil = 0
+	job.Progress = 0
+	job.ProgressMsg = ""
+	if err := item.w.handleJobAssign(item.msgID, job); err != nil {
+		if err != errWorkerDraining {
+			item.w.alive = false
+		}
+		return false
+	}
+	// the token is taken after the job is sent
+	sched.takeRateToken(job.Func)
 	sched.driver.Save(&job)
 	sched.incrStatProc(job)
 	sched.pushRevertPQ(job)
 	sched.notifyRevertTimer()
 	sched.procQueue[job.ID] = job
-	sched.grabQueue.remove(item)
+	sched.grabQueue.take(item)
+	sched.emit("assigned", job, item.w.name())
 	return true
 }
 
@@ -216,22 +424,33 @@ func (sched *Sched) lessItem() (lessItem *queue.Item) {
 		return sched.cacheItem
 	}
 	maybeItem := make(map[string]*queue.Item)
+	now := int64(time.Now().Unix())
+	sched.tokenWait = 0
 	sched.funcLocker.Lock()
 	for Func, stat := range sched.stats {
 		if stat.Worker.Int() == 0 {
 			continue
 		}
-		if _, err := sched.grabQueue.get(Func); err != nil {
+		if sched.isPaused(Func) {
 			continue
 		}
-		pq, ok := sched.jobPQ[Func]
-		if !ok || pq.Len() == 0 {
+		if sched.reachConcurrency(stat) {
+			continue
+		}
+		if _, err := sched.grabQueue.get(driver.Job{Func: Func}); err != nil {
+			continue
+		}
+		if sched.queueLen(Func) == 0 {
+			continue
+		}
+		if wait := sched.rateLimitWait(Func); wait > 0 {
+			if sched.tokenWait == 0 || wait < sched.tokenWait {
+				sched.tokenWait = wait
+			}
 			continue
 		}
 
-		item := heap.Pop(pq).(*queue.Item)
-
-		maybeItem[Func] = item
+		maybeItem[Func] = sched.popItem(Func, now)
 	}
 	sched.funcLocker.Unlock()
 
@@ -239,35 +458,106 @@ func (sched *Sched) lessItem() (lessItem *queue.Item) {
 		return nil
 	}
 
-	var lessFunc string
-
-	for Func, item := range maybeItem {
-		if lessItem == nil {
-			lessItem = item
-			lessFunc = Func
-			continue
-		}
-		if lessItem.Priority > item.Priority {
-			lessItem = item
-			lessFunc = Func
-		}
-	}
+	lessFunc := sched.policy.Choose(maybeItem, now)
+	lessItem = maybeItem[lessFunc]
 
 	for Func, item := range maybeItem {
 		if Func == lessFunc {
 			continue
 		}
-		pq := sched.jobPQ[Func]
-		old := pq.Get(item.Value)
-		if old != nil {
-			heap.Remove(pq, old.Index)
-		}
-		heap.Push(pq, item)
+		sched.pushItem(Func, item, now)
 	}
 	sched.cacheItem = lessItem
 	return
 }
 
+func (sched *Sched) queueLen(Func string) int {
+	var size = 0
+	if pq, ok := sched.jobPQ[Func]; ok {
+		size = size + pq.Len()
+	}
+	if rq, ok := sched.readyPQ[Func]; ok {
+		size = size + rq.Len()
+	}
+	return size
+}
+
+// popItem pop the first item to serve of func, the due items are moved to the
+// ready queue to be served by rank.
+func (sched *Sched) popItem(Func string, now int64) *queue.Item {
+	pq, ok := sched.jobPQ[Func]
+	if !ok {
+		return nil
+	}
+	rq := sched.readyPQ[Func]
+	for pq.Len() > 0 && (*pq)[0].Priority <= now {
+		heap.Push(rq, heap.Pop(pq))
+	}
+	if rq.Len() > 0 {
+		return heap.Pop(rq).(*queue.Item)
+	}
+	if pq.Len() > 0 {
+		return heap.Pop(pq).(*queue.Item)
+	}
+	return nil
+}
+
+// popDueItems pop at most n due items of func by rank
+func (sched *Sched) popDueItems(Func string, n int, now int64) []*queue.Item {
+	defer sched.PQLocker.Unlock()
+	sched.PQLocker.Lock()
+	var items = make([]*queue.Item, 0)
+	pq, ok := sched.jobPQ[Func]
+	if !ok {
+		return items
+	}
+	rq := sched.readyPQ[Func]
+	for pq.Len() > 0 && (*pq)[0].Priority <= now {
+		heap.Push(rq, heap.Pop(pq))
+	}
+	for len(items) < n && rq.Len() > 0 {
+		items = append(items, heap.Pop(rq).(*queue.Item))
+	}
+	return items
+}
+
+// pushItem push the item to the queues of func, replace the old one.
+func (sched *Sched) pushItem(Func string, item *queue.Item, now int64) {
+	pq, ok := sched.jobPQ[Func]
+	if !ok {
+		pq1 := make(queue.PriorityQueue, 0)
+		pq = &pq1
+		sched.jobPQ[Func] = pq
+		heap.Init(pq)
+		rq := new(queue.ReadyQueue)
+		sched.readyPQ[Func] = rq
+		heap.Init(rq)
+	}
+	rq := sched.readyPQ[Func]
+	if old := pq.Get(item.Value); old != nil {
+		heap.Remove(pq, old.Index)
+	}
+	if old := rq.Get(item.Value); old != nil {
+		heap.Remove(rq, old.Index)
+	}
+	if item.Priority <= now {
+		heap.Push(rq, item)
+	} else {
+		heap.Push(pq, item)
+	}
+}
+
+// nextDispatchWait returns the duration to wait at most d, until the next rate
+// limit token when some func is rate limited.
+func (sched *Sched) nextDispatchWait(d time.Duration) time.Duration {
+	defer sched.PQLocker.Unlock()
+	sched.PQLocker.Lock()
+	if sched.tokenWait > 0 && sched.tokenWait < d {
+		return sched.tokenWait
+	}
+	return d
+}
+
 func (sched *Sched) handleJobPQ() {
 	var current time.Time
 	var timestamp int64
@@ -284,7 +574,7 @@ func (sched *Sched) handleJobPQ() {
 		lessItem := sched.lessItem()
 
 		if lessItem == nil {
-			sched.resetJobTimer(time.Minute)
+			sched.resetJobTimer(sched.nextDispatchWait(time.Minute))
 			current = <-sched.jobTimer.C
 			continue
 		}
@@ -299,23 +589,42 @@ func (sched *Sched) handleJobPQ() {
 		timestamp = int64(time.Now().Unix())
 
 		if schedJob.SchedAt > timestamp {
-			sched.resetJobTimer(time.Second * time.Duration(schedJob.SchedAt-timestamp))
+			// the due jobs of a rate limited func are dispatched once
+			// the token refills, before the later job
+			sched.resetJobTimer(sched.nextDispatchWait(time.Second * time.Duration(schedJob.SchedAt-timestamp)))
 			current = <-sched.jobTimer.C
 			timestamp = int64(current.Unix())
 			if schedJob.SchedAt > timestamp {
+				sched.clearCacheItem()
 				sched.pushJobPQ(schedJob)
 				continue
 			}
 		}
 
-		grabItem, err := sched.grabQueue.get(schedJob.Func)
+		grabItem, err := sched.grabQueue.get(schedJob)
+		if err != nil && len(schedJob.Selector) > 0 {
+			// no waiting worker has the labels, keep the job aside
+			// until one grabs, so the other jobs are not blocked
+			sched.clearCacheItem()
+			sched.parkJob(schedJob)
+			continue
+		}
 		if err == nil {
-			if sched.submitJob(grabItem, schedJob) {
+			var ok bool
+			if schedJob.Broadcast {
+				ok = sched.submitBroadcast(schedJob)
+			} else {
+				ok = sched.submitJob(grabItem, schedJob)
+			}
+			if ok {
 				sched.clearCacheItem()
+				sched.policy.Served(schedJob.Func)
 			} else {
+				sched.clearCacheItem()
 				sched.pushJobPQ(schedJob)
 			}
 		} else {
+			sched.clearCacheItem()
 			sched.pushJobPQ(schedJob)
 		}
 	}
@@ -346,37 +655,49 @@ func (sched *Sched) handleRevertPQ() {
 			continue
 		}
 
-		revertJob, err := sched.driver.Get(item.Value)
-
-		if err != nil {
-			continue
-		}
-
 		timestamp = int64(time.Now().Unix())
 
 		if item.Priority > timestamp {
 			sched.resetRevertTimer(time.Second * time.Duration(item.Priority-timestamp))
 			current = <-sched.revTimer.C
 			timestamp = int64(current.Unix())
-			if item.Priority > timestamp {
-				sched.pushRevertPQ(revertJob)
-				continue
-			}
 		}
 
-		sched.decrStatProc(revertJob)
-		revertJob.SetReady()
-		sched.driver.Save(&revertJob)
-		sched.pushJobPQ(revertJob)
 		sched.jobLocker.Lock()
+		if !sched.alive {
+			sched.jobLocker.Unlock()
+			break
+		}
+		// the lease of the job may be extended while waiting
+		revertJob, err := sched.driver.Get(item.Value)
+		if err != nil || !revertJob.IsProc() {
+			sched.jobLocker.Unlock()
+			continue
+		}
+		if revertJob.Deadline() > timestamp {
+			sched.pushRevertPQ(revertJob)
+			sched.jobLocker.Unlock()
+			continue
+		}
+
+		if b, ok := sched.broadcasts[revertJob.ID]; ok {
+			sched.finishBroadcast(b, "timeout")
+			sched.jobLocker.Unlock()
+			continue
+		}
+
 		if _, ok := sched.procQueue[revertJob.ID]; ok {
 			delete(sched.procQueue, revertJob.ID)
 		}
+		sched.decrStatProc(revertJob)
+		sched.emit("timeout", revertJob, "")
+		sched.retryJob(revertJob)
 		sched.jobLocker.Unlock()
+		sched.notifyJobTimer()
 	}
 }
 
-func (sched *Sched) fail(jobID int64) {
+func (sched *Sched) fail(jobID int64, result []byte) {
 	defer sched.notifyJobTimer()
 	defer sched.notifyRevertTimer()
 	defer sched.jobLocker.Unlock()
@@ -384,13 +705,77 @@ func (sched *Sched) fail(jobID int64) {
 	if _, ok := sched.procQueue[jobID]; ok {
 		delete(sched.procQueue, jobID)
 	}
-	job, _ := sched.driver.Get(jobID)
+	job, err := sched.driver.Get(jobID)
+	// the job is reverted already
+	if err != nil || !job.IsProc() {
+		return
+	}
 	sched.decrStatProc(job)
 	sched.removeRevertPQ(job)
+	sched.saveResult(job, "fail", result)
+	sched.emit("failed", job, "")
+	sched.retryJob(job)
+	return
+}
+
+// retryJob set a failed or timed out job ready again with its retry policy.
+// The job is moved to dead letter when the attempts are exhausted, a cron job
+// waits the next fire time instead.
+func (sched *Sched) retryJob(job driver.Job) {
 	job.SetReady()
+	job.Attempts = job.Attempts + 1
+	policy := sched.retryPolicy(job)
+	if policy != nil {
+		if policy.Exhausted(job.Attempts) {
+			schedAt, ok := nextCronSchedAt(job)
+			if !ok {
+				log.Printf("Job %s:%s exhausted %d attempts, moved to dead letter\n", job.Func, job.Name, job.Attempts)
+				job.SetDead()
+				sched.driver.Save(&job)
+				sched.emit("dead", job, "")
+				return
+			}
+			job.SchedAt = schedAt
+			job.Attempts = 0
+		} else {
+			job.SchedAt = int64(time.Now().Unix()) + policy.Delay(job.Attempts)
+		}
+	}
 	sched.driver.Save(&job)
 	sched.pushJobPQ(job)
-	return
+}
+
+// extendLease push the timeout of a processing job to delay seconds later
+func (sched *Sched) extendLease(jobID, delay int64) {
+	defer sched.notifyRevertTimer()
+	defer sched.jobLocker.Unlock()
+	sched.jobLocker.Lock()
+	job, err := sched.driver.Get(jobID)
+	if err != nil || !job.IsProc() {
+		return
+	}
+	job.LeaseUntil = int64(time.Now().Unix()) + delay
+	sched.driver.Save(&job)
+	sched.procQueue[job.ID] = job
+	sched.pushRevertPQ(job)
+}
+
+// updateProgress store the progress of a processing job
+func (sched *Sched) updateProgress(jobID, progress int64, msg string) {
+	defer sched.jobLocker.Unlock()
+	sched.jobLocker.Lock()
+	job, err := sched.driver.Get(jobID)
+	if err != nil || !job.IsProc() {
+		return
+	}
+	if progress > 100 {
+		progress = 100
+	}
+	job.Progress = progress
+	job.ProgressMsg = msg
+	sched.driver.Save(&job)
+	sched.procQueue[job.ID] = job
+	sched.emit("progress", job, "")
 }
 
 func (sched *Sched) getFuncStat(Func string) *stat.FuncStat {
@@ -446,7 +831,11 @@ func (sched *Sched) schedLater(jobID, delay, counter int64) {
 	if _, ok := sched.procQueue[jobID]; ok {
 		delete(sched.procQueue, jobID)
 	}
-	job, _ := sched.driver.Get(jobID)
+	job, err := sched.driver.Get(jobID)
+	// the job is reverted already
+	if err != nil || !job.IsProc() {
+		return
+	}
 	sched.decrStatProc(job)
 	sched.removeRevertPQ(job)
 	job.SetReady()
@@ -455,6 +844,7 @@ func (sched *Sched) schedLater(jobID, delay, counter int64) {
 	job.Counter = job.Counter + counter
 	sched.driver.Save(&job)
 	sched.pushJobPQ(job)
+	sched.emit("rescheduled", job, "")
 	return
 }
 
@@ -465,45 +855,93 @@ func (sched *Sched) pushJobPQ(job driver.Job) bool {
 		item := &queue.Item{
 			Value:    job.ID,
 			Priority: job.SchedAt,
+			Rank:     job.Priority,
 		}
-		if sched.cacheItem != nil && item.Priority < sched.cacheItem.Priority {
+		now := int64(time.Now().Unix())
+		if sched.cacheItem != nil && queue.Less(item, sched.cacheItem, now) {
 			if job.ID == sched.cacheItem.Value {
 				return true
 			}
-			job, _ = sched.driver.Get(sched.cacheItem.Value)
+			// serve the new item first, put the cached one back
+			cacheItem := sched.cacheItem
 			sched.cacheItem = item
+			job, _ = sched.driver.Get(cacheItem.Value)
 			if job.ID <= 0 || !job.IsReady() {
 				return false
 			}
+			item = cacheItem
 		}
-		pq, ok := sched.jobPQ[job.Func]
-		if !ok {
-			pq1 := make(queue.PriorityQueue, 0)
-			pq = &pq1
-			sched.jobPQ[job.Func] = pq
-			heap.Init(pq)
-		}
-		old := pq.Get(item.Value)
-		if old != nil {
-			heap.Remove(pq, old.Index)
-		}
-		heap.Push(pq, item)
+		sched.pushItem(job.Func, item, now)
 		return true
 	}
 	return false
 }
 
+// parkJob keep the job waiting for a worker satisfy its selector, only the
+// func and the selector of the job are kept.
+func (sched *Sched) parkJob(job driver.Job) {
+	defer sched.PQLocker.Unlock()
+	sched.PQLocker.Lock()
+	jobs, ok := sched.parked[job.Func]
+	if !ok {
+		jobs = make(map[int64]driver.Job)
+		sched.parked[job.Func] = jobs
+	}
+	jobs[job.ID] = driver.Job{ID: job.ID, Func: job.Func, Selector: job.Selector}
+}
+
+// unparkJob remove the job from the parked jobs, returns false when the job is
+// not parked.
+func (sched *Sched) unparkJob(job driver.Job) bool {
+	defer sched.PQLocker.Unlock()
+	sched.PQLocker.Lock()
+	jobs, ok := sched.parked[job.Func]
+	if !ok {
+		return false
+	}
+	if _, ok := jobs[job.ID]; !ok {
+		return false
+	}
+	delete(jobs, job.ID)
+	if len(jobs) == 0 {
+		delete(sched.parked, job.Func)
+	}
+	return true
+}
+
+// unparkJobs push the parked jobs the worker can run back to the job queue,
+// only the jobs match the worker are read from the store.
+func (sched *Sched) unparkJobs(w *worker) {
+	item := grabItem{w: w}
+	var matched = make([]driver.Job, 0)
+	sched.PQLocker.Lock()
+	for _, Func := range w.getFuncs() {
+		for _, job := range sched.parked[Func] {
+			if item.has(job) {
+				matched = append(matched, job)
+			}
+		}
+	}
+	sched.PQLocker.Unlock()
+	for _, parked := range matched {
+		job, err := sched.driver.Get(parked.ID)
+		if err != nil || !job.IsReady() {
+			sched.unparkJob(parked)
+			continue
+		}
+		if item.has(job) && sched.unparkJob(parked) {
+			sched.pushJobPQ(job)
+		}
+	}
+}
+
 func (sched *Sched) pushRevertPQ(job driver.Job) {
 	defer sched.PQLocker.Unlock()
 	sched.PQLocker.Lock()
 	if job.IsProc() && job.Timeout > 0 {
-		runAt := job.RunAt
-		if runAt == 0 {
-			runAt = job.SchedAt
-		}
 		item := &queue.Item{
 			Value:    job.ID,
-			Priority: runAt + job.Timeout,
+			Priority: job.Deadline(),
 		}
 		old := sched.revertPQ.Get(item.Value)
 		if old != nil {
@@ -543,12 +981,18 @@ func (sched *Sched) loadJobQueue() {
 			continue
 		}
 		sched.incrStatJob(job)
+		if job.IsDead() {
+			continue
+		}
 		sched.pushJobPQ(job)
+		if !job.IsProc() {
+			continue
+		}
 		runAt := job.RunAt
 		if runAt < job.SchedAt {
 			runAt = job.SchedAt
 		}
-		if runAt+job.Timeout < current {
+		if runAt+job.Timeout < current && job.LeaseUntil < current {
 			updateQueue = append(updateQueue, job)
 		} else {
 			sched.jobLocker.Lock()
@@ -571,9 +1015,58 @@ func (sched *Sched) loadJobQueue() {
 	}
 }
 
-// Close the schedule
+// SetShutdownTimeout set how long Close waits for the processing jobs
+func (sched *Sched) SetShutdownTimeout(timeout time.Duration) {
+	sched.shutdownTimeout = timeout
+}
+
+// Close the schedule, stop accepting connections and dispatching jobs, wait
+// the processing jobs finished at most shutdownTimeout, the unfinished ones
+// are set ready to run again on the next start.
 func (sched *Sched) Close() {
 	sched.alive = false
+	sched.listenerLocker.Lock()
+	listeners := sched.listeners
+	sched.listenerLocker.Unlock()
+	for _, l := range listeners {
+		l.listen.Close()
+		l.httpServer.shutdown()
+	}
+	sched.notifyJobTimer()
+	sched.notifyRevertTimer()
+
+	deadline := time.Now().Add(sched.shutdownTimeout)
+	for time.Now().Before(deadline) {
+		sched.jobLocker.Lock()
+		size := len(sched.procQueue)
+		sched.jobLocker.Unlock()
+		if size == 0 {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	sched.jobLocker.Lock()
+	for jobID := range sched.procQueue {
+		job, err := sched.driver.Get(jobID)
+		if err == nil && job.IsProc() {
+			job.SetReady()
+			sched.driver.Save(&job)
+		}
+		delete(sched.procQueue, jobID)
+	}
+	sched.jobLocker.Unlock()
+
+	sched.workerLocker.Lock()
+	for w := range sched.workers {
+		w.conn.Close()
+	}
+	sched.workerLocker.Unlock()
+	// the closed workers may still fail their jobs to the store
+	sched.workerGroup.Wait()
+
+	defer sched.jobLocker.Unlock()
+	sched.jobLocker.Lock()
 	sched.driver.Close()
 	log.Printf("Periodic task system shutdown\n")
 }
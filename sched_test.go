@@ -0,0 +1,210 @@
+package periodic
+
+import (
+	"bytes"
+	"github.com/Lupino/go-periodic/protocol"
+	"github.com/Lupino/periodic/driver"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestWorker connect a worker can do the funcs, the data sent to the
+// worker are received from the returned channel.
+func newTestWorker(sched *Sched, funcs ...string) (*worker, chan []byte) {
+	server, client := net.Pipe()
+	w := newWorker(sched, protocol.NewServerConn(server))
+	sched.addWorker(w)
+	for _, Func := range funcs {
+		w.handleCanDo(Func)
+	}
+	ch := make(chan []byte, 16)
+	go func() {
+		defer close(ch)
+		buf := make([]byte, 4096)
+		for {
+			n, err := client.Read(buf)
+			if err != nil {
+				return
+			}
+			ch <- append([]byte(nil), buf[:n]...)
+		}
+	}()
+	return w, ch
+}
+
+// waitAssigned wait the worker receive a job with one of the names
+func waitAssigned(ch chan []byte, timeout time.Duration, names ...string) string {
+	deadline := time.After(timeout)
+	for {
+		select {
+		case data, ok := <-ch:
+			if !ok {
+				return ""
+			}
+			for _, name := range names {
+				if bytes.Contains(data, []byte(name)) {
+					return name
+				}
+			}
+		case <-deadline:
+			return ""
+		}
+	}
+}
+
+func TestRateLimitedDueJobBeforeLaterJob(t *testing.T) {
+	sched := NewSched(nil, driver.NewMemStroeDriver(), 0)
+	defer func() {
+		sched.alive = false
+		sched.notifyJobTimer()
+	}()
+	if err := sched.SetRateLimit("rate", "1/s"); err != nil {
+		t.Fatalf("SetRateLimit: %s\n", err)
+	}
+	w, ch := newTestWorker(sched, "rate", "later")
+	go sched.handleJobPQ()
+
+	now := time.Now().Unix()
+	var jobs = []driver.Job{
+		{Func: "rate", Name: "rate-job-1", SchedAt: now},
+		{Func: "rate", Name: "rate-job-2", SchedAt: now},
+		{Func: "later", Name: "later-job", SchedAt: now + 60},
+	}
+	for i := range jobs {
+		if err := sched.addJob(&jobs[i]); err != nil {
+			t.Fatalf("addJob: %s\n", err)
+		}
+	}
+
+	w.handleGrabJob([]byte("0001"), 1)
+	first := waitAssigned(ch, 2*time.Second, "rate-job-1", "rate-job-2", "later-job")
+	if first != "rate-job-1" && first != "rate-job-2" {
+		t.Fatalf("First assigned: except: rate-job, got: %q\n", first)
+	}
+	// the rate func is out of tokens, the later job of the other func must
+	// not hold the refilled token back
+	w.handleGrabJob([]byte("0002"), 1)
+	second := waitAssigned(ch, 3*time.Second, "rate-job-1", "rate-job-2", "later-job")
+	if second == "" || second == first || second == "later-job" {
+		t.Fatalf("Second assigned: except: the other rate-job, got: %q\n", second)
+	}
+}
+
+// closeCheckStore record the store calls after the store is closed
+type closeCheckStore struct {
+	*driver.MemStoreDriver
+	closed bool
+	misuse int
+	locker *sync.Mutex
+}
+
+func (s *closeCheckStore) check() {
+	defer s.locker.Unlock()
+	s.locker.Lock()
+	if s.closed {
+		s.misuse++
+	}
+}
+
+func (s *closeCheckStore) Get(jobID int64) (driver.Job, error) {
+	s.check()
+	return s.MemStoreDriver.Get(jobID)
+}
+
+func (s *closeCheckStore) Save(job *driver.Job, force ...bool) error {
+	s.check()
+	return s.MemStoreDriver.Save(job, force...)
+}
+
+func (s *closeCheckStore) Close() error {
+	defer s.locker.Unlock()
+	s.locker.Lock()
+	s.closed = true
+	return nil
+}
+
+func TestCloseWaitWorkers(t *testing.T) {
+	store := &closeCheckStore{MemStoreDriver: driver.NewMemStroeDriver(), locker: new(sync.Mutex)}
+	sched := NewSched(nil, store, 0)
+	sched.SetShutdownTimeout(0)
+	w, ch := newTestWorker(sched, "test")
+	go func() {
+		w.handle()
+		sched.workerGroup.Done()
+	}()
+	go sched.handleJobPQ()
+
+	job := driver.Job{Func: "test", Name: "test-job"}
+	if err := sched.addJob(&job); err != nil {
+		t.Fatalf("addJob: %s\n", err)
+	}
+	w.handleGrabJob([]byte("0001"), 1)
+	if name := waitAssigned(ch, 2*time.Second, "test-job"); name == "" {
+		t.Fatalf("Assigned: except: test-job, got: nothing\n")
+	}
+	sched.Close()
+	time.Sleep(100 * time.Millisecond)
+	store.locker.Lock()
+	misuse := store.misuse
+	store.locker.Unlock()
+	if misuse > 0 {
+		t.Fatalf("Store used after close: except: 0, got: %d\n", misuse)
+	}
+}
+
+// countStore count the jobs read from the store
+type countStore struct {
+	*driver.MemStoreDriver
+	gets   int
+	locker *sync.Mutex
+}
+
+func (s *countStore) Get(jobID int64) (driver.Job, error) {
+	s.locker.Lock()
+	s.gets++
+	s.locker.Unlock()
+	return s.MemStoreDriver.Get(jobID)
+}
+
+func (s *countStore) count() int {
+	defer s.locker.Unlock()
+	s.locker.Lock()
+	gets := s.gets
+	s.gets = 0
+	return gets
+}
+
+func TestUnparkJobs(t *testing.T) {
+	store := &countStore{MemStoreDriver: driver.NewMemStroeDriver(), locker: new(sync.Mutex)}
+	sched := NewSched(nil, store, 0)
+	for i := 0; i < 10; i++ {
+		job := driver.Job{Func: "gpu", Name: "gpu-job-" + strconv.Itoa(i), Selector: map[string]string{"gpu": "true"}}
+		if err := sched.addJob(&job); err != nil {
+			t.Fatalf("addJob: %s\n", err)
+		}
+		sched.parkJob(job)
+	}
+	store.count()
+
+	cpu, _ := newTestWorker(sched, "cpu")
+	cpu.handleGrabJob([]byte("0001"), 1)
+	if gets := store.count(); gets != 0 {
+		t.Fatalf("Get of other func: except: 0, got: %d\n", gets)
+	}
+	nolabel, _ := newTestWorker(sched, "gpu")
+	nolabel.handleGrabJob([]byte("0001"), 1)
+	if gets := store.count(); gets != 0 {
+		t.Fatalf("Get without labels: except: 0, got: %d\n", gets)
+	}
+	gpu, _ := newTestWorker(sched, "gpu")
+	gpu.handleLabels([]byte(`{"gpu":"true"}`))
+	if gets := store.count(); gets != 10 {
+		t.Fatalf("Get with labels: except: 10, got: %d\n", gets)
+	}
+	if len(sched.parked) != 0 {
+		t.Fatalf("parked: except: 0, got: %d\n", len(sched.parked))
+	}
+}
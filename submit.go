@@ -0,0 +1,99 @@
+package periodic
+
+import (
+	"fmt"
+	"github.com/Lupino/periodic/driver"
+)
+
+// addJob save a new job or update the exists one with the same func and
+// name, then schedule it.
+func (sched *Sched) addJob(job *driver.Job) error {
+	defer sched.jobLocker.Unlock()
+	sched.jobLocker.Lock()
+	return sched.saveJob(job)
+}
+
+// addWaitJob add a job like addJob, the returned channel receive the result
+// when the job is done or failed.
+func (sched *Sched) addWaitJob(job *driver.Job) (chan driver.Result, error) {
+	defer sched.jobLocker.Unlock()
+	sched.jobLocker.Lock()
+	if err := sched.saveJob(job); err != nil {
+		return nil, err
+	}
+	ch := make(chan driver.Result, 1)
+	sched.waitLocker.Lock()
+	sched.waiters[job.ID] = append(sched.waiters[job.ID], ch)
+	sched.waitLocker.Unlock()
+	return ch, nil
+}
+
+func (sched *Sched) saveJob(job *driver.Job) error {
+	if err := checkCron(job); err != nil {
+		return err
+	}
+	// the workers without waiting grab may never reply a broadcast job
+	if job.Broadcast && job.Timeout <= 0 {
+		return fmt.Errorf("Job %s:%s broadcast requires a timeout.", job.Func, job.Name)
+	}
+	isNew := true
+	changed := false
+	job.SetReady()
+	oldJob, e := sched.driver.GetOne(job.Func, job.Name)
+	if e == nil && oldJob.ID > 0 {
+		job.ID = oldJob.ID
+		if oldJob.IsProc() {
+			sched.decrStatProc(oldJob)
+			sched.removeRevertPQ(oldJob)
+			changed = true
+		}
+		if oldJob.IsDead() {
+			changed = true
+		}
+		// the selector may be changed, match it again
+		if sched.unparkJob(oldJob) {
+			changed = true
+		}
+		isNew = false
+	}
+	if err := sched.driver.Save(job); err != nil {
+		return err
+	}
+
+	if isNew {
+		sched.incrStatJob(*job)
+	}
+	if isNew || changed {
+		sched.pushJobPQ(*job)
+	}
+	sched.notifyJobTimer()
+	sched.emit("submitted", *job, "")
+	return nil
+}
+
+// removeWaiter stop waiting the result of job on ch
+func (sched *Sched) removeWaiter(jobID int64, ch chan driver.Result) {
+	defer sched.waitLocker.Unlock()
+	sched.waitLocker.Lock()
+	var waiters = make([]chan driver.Result, 0)
+	for _, w := range sched.waiters[jobID] {
+		if w != ch {
+			waiters = append(waiters, w)
+		}
+	}
+	if len(waiters) == 0 {
+		delete(sched.waiters, jobID)
+	} else {
+		sched.waiters[jobID] = waiters
+	}
+}
+
+// notifyWaiters send the result of job to its waiters
+func (sched *Sched) notifyWaiters(jobID int64, r driver.Result) {
+	defer sched.waitLocker.Unlock()
+	sched.waitLocker.Lock()
+	for _, ch := range sched.waiters[jobID] {
+		ch <- r
+	}
+	delete(sched.waiters, jobID)
+}
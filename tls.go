@@ -0,0 +1,90 @@
+package periodic
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"sync"
+)
+
+// tlsFiles defined the certificate files of the TLS listener, the files are
+// loaded again on reload.
+type tlsFiles struct {
+	certFile string
+	keyFile  string
+	caFile   string
+	config   *tls.Config
+	locker   *sync.Mutex
+}
+
+// loadTLSConfig load the server certificate, the client certificates are
+// required and verified with the ca when caFile is set.
+func loadTLSConfig(certFile, keyFile, caFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if caFile != "" {
+		data, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, errors.New("no certificate found in " + caFile)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
+
+// SetTLS serve the tcp entry point with TLS, the clients must present a
+// certificate signed by caFile when it is not empty.
+func (sched *Sched) SetTLS(certFile, keyFile, caFile string) error {
+	config, err := loadTLSConfig(certFile, keyFile, caFile)
+	if err != nil {
+		return err
+	}
+	sched.tls = &tlsFiles{
+		certFile: certFile,
+		keyFile:  keyFile,
+		caFile:   caFile,
+		config:   config,
+		locker:   new(sync.Mutex),
+	}
+	return nil
+}
+
+// ReloadTLS load the certificate files again, the new connections use the
+// new certificates. The old certificates are kept on error.
+func (sched *Sched) ReloadTLS() error {
+	if sched.tls == nil {
+		return nil
+	}
+	t := sched.tls
+	config, err := loadTLSConfig(t.certFile, t.keyFile, t.caFile)
+	if err != nil {
+		return err
+	}
+	defer t.locker.Unlock()
+	t.locker.Lock()
+	t.config = config
+	return nil
+}
+
+// serverConfig returns a TLS config always uses the last loaded certificates
+func (t *tlsFiles) serverConfig() *tls.Config {
+	return &tls.Config{
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			defer t.locker.Unlock()
+			t.locker.Lock()
+			return t.config, nil
+		},
+	}
+}
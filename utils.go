@@ -1,9 +1,13 @@
 package periodic
 
 import (
+	"fmt"
+	"github.com/Lupino/periodic/cron"
+	"github.com/Lupino/periodic/driver"
 	"log"
 	"net"
 	"os"
+	"time"
 )
 
 func sockCheck(sockFile string) {
@@ -17,3 +21,41 @@ func sockCheck(sockFile string) {
 		os.Remove(sockFile)
 	}
 }
+
+// checkCron validate the cron expression of the job, a cron job without
+// sched_at is scheduled at the next fire time.
+func checkCron(job *driver.Job) error {
+	if job.Cron == "" {
+		return nil
+	}
+	schedule, err := cron.Parse(job.Cron)
+	if err != nil {
+		return err
+	}
+	next := schedule.Next(time.Now())
+	if next.IsZero() {
+		return fmt.Errorf("cron %q never fires", job.Cron)
+	}
+	if job.SchedAt == 0 {
+		job.SchedAt = next.Unix()
+	}
+	return nil
+}
+
+// nextCronSchedAt returns the next fire time of a cron job, false when the job
+// is not recurring.
+func nextCronSchedAt(job driver.Job) (int64, bool) {
+	if job.Cron == "" {
+		return 0, false
+	}
+	schedule, err := cron.Parse(job.Cron)
+	if err != nil {
+		log.Printf("Job %s:%s invalid cron %q: %v\n", job.Func, job.Name, job.Cron, err)
+		return 0, false
+	}
+	next := schedule.Next(time.Now())
+	if next.IsZero() {
+		return 0, false
+	}
+	return next.Unix(), true
+}
@@ -3,20 +3,53 @@ package periodic
 import (
 	"bytes"
 	"encoding/binary"
+	"encoding/json"
+	"errors"
 	"github.com/Lupino/go-periodic/protocol"
 	"github.com/Lupino/periodic/driver"
 	"io"
 	"log"
+	"sort"
 	"sync"
+	"time"
 )
 
+var errWorkerDraining = errors.New("worker is draining")
+
 type worker struct {
-	jobQueue map[int64]driver.Job
-	conn     protocol.Conn
-	sched    *Sched
-	alive    bool
-	funcs    []string
-	locker   *sync.Mutex
+	jobQueue  map[int64]driver.Job
+	conn      protocol.Conn
+	sched     *Sched
+	alive     bool
+	funcs     []string
+	locker    *sync.Mutex
+	heartbeat time.Duration
+	deadline  time.Time // The read deadline of the listener
+	labels    map[string]string
+	id        string
+	hostname  string
+	version   string
+	since     int64
+	done      int64
+	failed    int64
+	draining  bool
+	identity  string // The identity authenticated on connect
+}
+
+// WorkerInfo defined the state of a connected worker
+type WorkerInfo struct {
+	ID          string            `json:"id"`
+	Hostname    string            `json:"hostname"`
+	Version     string            `json:"version"`
+	Addr        string            `json:"addr"`
+	Funcs       []string          `json:"funcs"`
+	Labels      map[string]string `json:"labels"`
+	ConnectedAt int64             `json:"connected_at"`
+	Jobs        []driver.Job      `json:"jobs"` // The jobs the worker is running
+	Done        int64             `json:"done"`
+	Failed      int64             `json:"failed"`
+	Draining    bool              `json:"draining"`
+	Slots       int               `json:"slots"` // The jobs the worker is waiting for
 }
 
 func encodeJobHandle(id int64) []byte {
@@ -42,9 +75,63 @@ func newWorker(sched *Sched, conn protocol.Conn) (w *worker) {
 	w.funcs = make([]string, 0)
 	w.alive = true
 	w.locker = new(sync.Mutex)
+	w.since = time.Now().Unix()
 	return
 }
 
+// name returns the registered id of the worker, the remote address if not
+// registered.
+func (w *worker) name() string {
+	defer w.locker.Unlock()
+	w.locker.Lock()
+	if w.id != "" {
+		return w.id
+	}
+	return w.conn.RemoteAddr().String()
+}
+
+func (w *worker) info() WorkerInfo {
+	info := WorkerInfo{
+		ID:          w.name(),
+		Addr:        w.conn.RemoteAddr().String(),
+		ConnectedAt: w.since,
+		Jobs:        make([]driver.Job, 0),
+		Slots:       w.sched.grabQueue.slots(w),
+	}
+	defer w.locker.Unlock()
+	w.locker.Lock()
+	info.Hostname = w.hostname
+	info.Version = w.version
+	info.Funcs = append([]string{}, w.funcs...)
+	info.Labels = w.labels
+	info.Done = w.done
+	info.Failed = w.failed
+	info.Draining = w.draining
+	for _, job := range w.jobQueue {
+		info.Jobs = append(info.Jobs, job)
+	}
+	sort.Slice(info.Jobs, func(i, j int) bool {
+		return info.Jobs[i].ID < info.Jobs[j].ID
+	})
+	return info
+}
+
+// handleRegister set the id, hostname and version of the worker from a json
+// object.
+func (w *worker) handleRegister(payload []byte) (err error) {
+	var info WorkerInfo
+	if err = json.Unmarshal(payload, &info); err != nil {
+		log.Printf("workerError: invalid register %q: %s\n", payload, err)
+		return nil
+	}
+	defer w.locker.Unlock()
+	w.locker.Lock()
+	w.id = info.ID
+	w.hostname = info.Hostname
+	w.version = info.Version
+	return nil
+}
+
 func (w *worker) IsAlive() bool {
 	return w.alive
 }
@@ -52,6 +139,10 @@ func (w *worker) IsAlive() bool {
 func (w *worker) handleJobAssign(msgID []byte, job driver.Job) (err error) {
 	defer w.locker.Unlock()
 	w.locker.Lock()
+	// the drain may begin after the grab is taken
+	if w.draining {
+		return errWorkerDraining
+	}
 	w.jobQueue[job.ID] = job
 	buf := bytes.NewBuffer(nil)
 	buf.Write(msgID)
@@ -63,17 +154,20 @@ func (w *worker) handleJobAssign(msgID []byte, job driver.Job) (err error) {
 }
 
 func (w *worker) handleCanDo(Func string) error {
-	for _, f := range w.funcs {
-		if f == Func {
-			return nil
-		}
+	w.locker.Lock()
+	if hasFunc(w.funcs, Func) {
+		w.locker.Unlock()
+		return nil
 	}
 	w.funcs = append(w.funcs, Func)
+	w.locker.Unlock()
 	w.sched.incrStatFunc(Func)
 	return nil
 }
 
 func (w *worker) handleCanNoDo(Func string) error {
+	defer w.locker.Unlock()
+	w.locker.Lock()
 	var newFuncs = make([]string, 0)
 	for _, f := range w.funcs {
 		if f == Func {
@@ -85,22 +179,42 @@ func (w *worker) handleCanNoDo(Func string) error {
 	return nil
 }
 
-func (w *worker) handleDone(jobID int64) (err error) {
-	w.sched.done(jobID)
+func (w *worker) getFuncs() []string {
+	defer w.locker.Unlock()
+	w.locker.Lock()
+	return append([]string{}, w.funcs...)
+}
+
+func (w *worker) canDo(Func string) bool {
+	defer w.locker.Unlock()
+	w.locker.Lock()
+	return hasFunc(w.funcs, Func)
+}
+
+func (w *worker) handleDone(jobID int64, result []byte) (err error) {
+	defer w.closeIfDrained()
+	if !w.sched.broadcastFinish(jobID, w, "done") {
+		w.sched.done(jobID, result)
+	}
 	defer w.locker.Unlock()
 	w.locker.Lock()
 	if _, ok := w.jobQueue[jobID]; ok {
 		delete(w.jobQueue, jobID)
+		w.done++
 	}
 	return nil
 }
 
-func (w *worker) handleFail(jobID int64) (err error) {
-	w.sched.fail(jobID)
+func (w *worker) handleFail(jobID int64, result []byte) (err error) {
+	defer w.closeIfDrained()
+	if !w.sched.broadcastFinish(jobID, w, "fail") {
+		w.sched.fail(jobID, result)
+	}
 	defer w.locker.Unlock()
 	w.locker.Lock()
 	if _, ok := w.jobQueue[jobID]; ok {
 		delete(w.jobQueue, jobID)
+		w.failed++
 	}
 	return nil
 }
@@ -114,7 +228,10 @@ func (w *worker) handleCommand(msgID []byte, cmd protocol.Command) (err error) {
 }
 
 func (w *worker) handleSchedLater(jobID, delay, counter int64) (err error) {
-	w.sched.schedLater(jobID, delay, counter)
+	defer w.closeIfDrained()
+	if !w.sched.broadcastFinish(jobID, w, "fail") {
+		w.sched.schedLater(jobID, delay, counter)
+	}
 	defer w.locker.Unlock()
 	w.locker.Lock()
 	if _, ok := w.jobQueue[jobID]; ok {
@@ -123,12 +240,132 @@ func (w *worker) handleSchedLater(jobID, delay, counter int64) (err error) {
 	return nil
 }
 
-func (w *worker) handleGrabJob(msgID []byte) (err error) {
+func (w *worker) handleExtendLease(jobID, delay int64) (err error) {
+	w.locker.Lock()
+	_, ok := w.jobQueue[jobID]
+	w.locker.Unlock()
+	if ok {
+		w.sched.extendLease(jobID, delay)
+	}
+	return nil
+}
+
+// handleHeartbeat set the interval the worker sends messages in, the worker
+// is closed and its jobs are failed when nothing received in two intervals.
+func (w *worker) handleHeartbeat(interval int64) (err error) {
+	w.heartbeat = time.Duration(interval) * time.Second
+	if w.heartbeat == 0 {
+		err = w.conn.SetReadDeadline(w.deadline)
+	}
+	return
+}
+
+func (w *worker) handleProgress(jobID, progress int64, msg string) (err error) {
+	w.locker.Lock()
+	job, ok := w.jobQueue[jobID]
+	if ok {
+		job.Progress = progress
+		job.ProgressMsg = msg
+		w.jobQueue[jobID] = job
+	}
+	w.locker.Unlock()
+	if ok {
+		w.sched.updateProgress(jobID, progress, msg)
+	}
+	return nil
+}
+
+func (w *worker) isDraining() bool {
+	defer w.locker.Unlock()
+	w.locker.Lock()
+	return w.draining
+}
+
+// drain stop assigning jobs to the worker, the worker is closed once the jobs
+// it is running are finished.
+func (w *worker) drain() {
+	w.sched.grabQueue.drainWorker(w)
+	w.closeIfDrained()
+}
+
+func (w *worker) closeIfDrained() {
+	w.locker.Lock()
+	drained := w.draining && len(w.jobQueue) == 0
+	w.locker.Unlock()
+	if drained {
+		w.alive = false
+		w.conn.Close()
+	}
+}
+
+func (w *worker) matchLabels(job driver.Job) bool {
+	defer w.locker.Unlock()
+	w.locker.Lock()
+	return job.MatchLabels(w.labels)
+}
+
+// handleLabels set the labels of the worker, the jobs with selector are only
+// assigned to the worker has the labels.
+func (w *worker) handleLabels(payload []byte) (err error) {
+	var labels map[string]string
+	if err = json.Unmarshal(payload, &labels); err != nil {
+		log.Printf("workerError: invalid labels %q: %s\n", payload, err)
+		return nil
+	}
+	w.locker.Lock()
+	w.labels = labels
+	w.locker.Unlock()
+	w.sched.unparkJobs(w)
+	return nil
+}
+
+func (w *worker) hasJob(jobID int64) bool {
+	defer w.locker.Unlock()
+	w.locker.Lock()
+	_, ok := w.jobQueue[jobID]
+	return ok
+}
+
+// handleCancel tell the worker to cancel the job, the job is kept in the
+// jobQueue until the worker acknowledge it.
+func (w *worker) handleCancel(job driver.Job) (err error) {
+	defer w.locker.Unlock()
+	w.locker.Lock()
+	buf := bytes.NewBuffer(nil)
+	buf.Write(make([]byte, 4))
+	buf.Write(CANCELJOB.Bytes())
+	buf.Write(encodeJobHandle(job.ID))
+	err = w.conn.Send(buf.Bytes())
+	return
+}
+
+func (w *worker) handleCancelAck(jobID int64) (err error) {
+	defer w.closeIfDrained()
+	w.locker.Lock()
+	job, ok := w.jobQueue[jobID]
+	if ok {
+		delete(w.jobQueue, jobID)
+	}
+	w.locker.Unlock()
+	if ok {
+		w.sched.emit("cancel_acked", job, w.name())
+	}
+	return nil
+}
+
+// handleGrabJob wait for slots jobs, the jobs are assigned with the same msgID
+func (w *worker) handleGrabJob(msgID []byte, slots int) (err error) {
+	if slots < 1 {
+		slots = 1
+	}
 	item := grabItem{
 		w:     w,
 		msgID: msgID,
+		slots: slots,
 	}
 	w.sched.grabQueue.push(item)
+	w.sched.assignBroadcasts(w)
+	w.sched.unparkJobs(w)
 	w.sched.notifyJobTimer()
 	return nil
 }
@@ -145,7 +382,14 @@ func (w *worker) handle() {
 		}
 	}()
 	defer w.Close()
+	if e := w.sched.authorize(w.identity, "worker", "", ""); e != nil {
+		w.conn.Send([]byte(e.Error()))
+		return
+	}
 	for {
+		if w.heartbeat > 0 {
+			conn.SetReadDeadline(time.Now().Add(2 * w.heartbeat))
+		}
 		payload, err = conn.Receive()
 		if err != nil {
 			if err != io.EOF {
@@ -158,15 +402,20 @@ func (w *worker) handle() {
 
 		switch cmd {
 		case protocol.GRABJOB:
-			err = w.handleGrabJob(msgID)
+			// an optional two bytes count to grab multiple jobs
+			slots := 1
+			if len(payload) >= 2 {
+				slots = int(binary.BigEndian.Uint16(payload[0:2]))
+			}
+			err = w.handleGrabJob(msgID, slots)
 			break
 		case protocol.WORKDONE:
 			jobID := decodeJobHandle(payload)
-			err = w.handleDone(jobID)
+			err = w.handleDone(jobID, payload[9:])
 			break
 		case protocol.WORKFAIL:
 			jobID := decodeJobHandle(payload)
-			err = w.handleFail(jobID)
+			err = w.handleFail(jobID, payload[9:])
 			break
 		case protocol.SCHEDLATER:
 			jh := payload[0:9]
@@ -178,6 +427,46 @@ func (w *worker) handle() {
 			counter := int64(binary.BigEndian.Uint16(h16))
 			err = w.handleSchedLater(jobID, delay, counter)
 			break
+		case EXTENDLEASE:
+			if len(payload) < 17 {
+				err = w.handleCommand(msgID, protocol.UNKNOWN)
+				break
+			}
+			jobID := decodeJobHandle(payload[0:9])
+			delay := int64(binary.BigEndian.Uint64(payload[9:17]))
+			err = w.handleExtendLease(jobID, delay)
+			break
+		case HEARTBEAT:
+			if len(payload) < 8 {
+				err = w.handleCommand(msgID, protocol.UNKNOWN)
+				break
+			}
+			interval := int64(binary.BigEndian.Uint64(payload[0:8]))
+			err = w.handleHeartbeat(interval)
+			break
+		case PROGRESS:
+			if len(payload) < 10 {
+				err = w.handleCommand(msgID, protocol.UNKNOWN)
+				break
+			}
+			jobID := decodeJobHandle(payload[0:9])
+			progress := int64(payload[9])
+			err = w.handleProgress(jobID, progress, string(payload[10:]))
+			break
+		case REGISTER:
+			err = w.handleRegister(payload)
+			break
+		case LABELS:
+			err = w.handleLabels(payload)
+			break
+		case CANCELACK:
+			if len(payload) < 9 {
+				err = w.handleCommand(msgID, protocol.UNKNOWN)
+				break
+			}
+			jobID := decodeJobHandle(payload[0:9])
+			err = w.handleCancelAck(jobID)
+			break
 		case protocol.SLEEP:
 			err = w.handleCommand(msgID, protocol.NOOP)
 			break
@@ -185,7 +474,10 @@ func (w *worker) handle() {
 			err = w.handleCommand(msgID, protocol.PONG)
 			break
 		case protocol.CANDO:
-			err = w.handleCanDo(string(payload[1:]))
+			// the func denied is not registered
+			if w.sched.authorize(w.identity, "worker", "", string(payload[1:])) == nil {
+				err = w.handleCanDo(string(payload[1:]))
+			}
 			break
 		case protocol.CANTDO:
 			err = w.handleCanNoDo(string(payload[1:]))
@@ -210,13 +502,20 @@ func (w *worker) handle() {
 func (w *worker) Close() {
 	defer w.sched.notifyJobTimer()
 	defer w.conn.Close()
+	w.sched.removeWorker(w)
 	w.sched.grabQueue.removeWorker(w)
 	w.alive = false
 	for k := range w.jobQueue {
-		w.sched.fail(k)
+		if !w.sched.broadcastFinish(k, w, "fail") {
+			w.sched.fail(k, nil)
+		}
 	}
+	w.locker.Lock()
 	w.jobQueue = nil
-	for _, Func := range w.funcs {
+	funcs := w.funcs
+	w.locker.Unlock()
+	w.sched.leaveBroadcasts(w)
+	for _, Func := range funcs {
 		w.sched.decrStatFunc(Func)
 	}
 	w = nil
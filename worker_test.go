@@ -0,0 +1,46 @@
+package periodic
+
+import (
+	"github.com/Lupino/periodic/driver"
+	"testing"
+)
+
+func TestWorkerFuncs(t *testing.T) {
+	sched := NewSched(nil, driver.NewMemStroeDriver(), 0)
+	w, _ := newTestWorker(sched)
+	done := make(chan bool)
+	go func() {
+		for i := 0; i < 100; i++ {
+			w.handleCanDo("test")
+			w.handleCanNoDo("test")
+		}
+		w.handleCanDo("test")
+		done <- true
+	}()
+	for i := 0; i < 100; i++ {
+		sched.listWorkers("test")
+		(grabItem{w: w}).has(driver.Job{Func: "test"})
+	}
+	<-done
+	if infos := sched.listWorkers("test"); len(infos) != 1 {
+		t.Fatalf("listWorkers: except: 1, got: %d\n", len(infos))
+	}
+}
+
+func TestWorkerDrain(t *testing.T) {
+	sched := NewSched(nil, driver.NewMemStroeDriver(), 0)
+	w, _ := newTestWorker(sched, "test")
+	w.handleGrabJob([]byte("0001"), 2)
+	w.handleGrabJob([]byte("0002"), 1)
+	if slots := sched.grabQueue.slots(w); slots != 3 {
+		t.Fatalf("slots: except: 3, got: %d\n", slots)
+	}
+	w.drain()
+	if slots := sched.grabQueue.slots(w); slots != 0 {
+		t.Fatalf("slots after drain: except: 0, got: %d\n", slots)
+	}
+	job := driver.Job{ID: 1, Func: "test", Name: "test-job"}
+	if err := w.handleJobAssign([]byte("0001"), job); err != errWorkerDraining {
+		t.Fatalf("handleJobAssign: except: %s, got: %v\n", errWorkerDraining, err)
+	}
+}